@@ -0,0 +1,659 @@
+// REST HTTP接口，用于作业管理和调度器控制，请求和响应均为JSON，接口描述见OpenAPI
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xnffdd/gospider/logs"
+	"github.com/xnffdd/gospider/scheduler"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 单个请求等待调度器执行指令的最长时间
+const commandTimeout = 30 * time.Second
+
+// 作业的JSON表示
+type jobView struct {
+	Id         string      `json:"id"`
+	Name       string      `json:"name"`
+	CronRule   string      `json:"cron_rule"`
+	TimeZone   string      `json:"time_zone"`
+	RunnerName string      `json:"runner_name"`
+	RunnerArgs string      `json:"runner_args"`
+	Timeout    int64       `json:"timeout_seconds"`
+	Policy     string      `json:"concurrency_policy"`
+	Retry      retryView   `json:"retry"`
+	Misfire    misfireView `json:"misfire"`
+	Opened     bool        `json:"opened"`
+	CreateTime time.Time   `json:"create_time"`
+	UpdateTime time.Time   `json:"update_time"`
+	Next       *time.Time  `json:"next,omitempty"` // 下一次执行时刻，未调度时省略
+}
+
+func newJobView(job *scheduler.Job) *jobView {
+	v := &jobView{
+		Id:         job.Id,
+		Name:       job.Name,
+		CronRule:   job.CronRule,
+		TimeZone:   job.TimeZone,
+		RunnerName: job.RunnerName,
+		RunnerArgs: job.RunnerArgs,
+		Timeout:    int64(job.Timeout / time.Second),
+		Policy:     job.ConcurrencyPolicy,
+		Retry:      newRetryView(&job.Retry),
+		Misfire:    newMisfireView(&job.Misfire),
+		Opened:     job.Opened,
+		CreateTime: job.CreateTime,
+		UpdateTime: job.UpdateTime,
+	}
+	if !job.Next.IsZero() {
+		next := job.Next
+		v.Next = &next
+	}
+	return v
+}
+
+// 新建、更新作业的请求体
+type jobRequest struct {
+	Name       string      `json:"name"`
+	CronRule   string      `json:"cron_rule"`
+	TimeZone   string      `json:"time_zone"` // 调度规则的IANA时区，省略时为服务器时区
+	RunnerName string      `json:"runner_name"`
+	RunnerArgs string      `json:"runner_args"`
+	Timeout    int64       `json:"timeout_seconds"`    // 单次执行超时时间（秒），0表示不限制
+	Policy     string      `json:"concurrency_policy"` // 并发策略，默认ALLOW
+	Retry      retryView   `json:"retry"`              // 重试策略，默认不重试
+	Misfire    misfireView `json:"misfire"`            // 错过执行的处理策略，默认忽略
+}
+
+func (req *jobRequest) jobCore(id string) *scheduler.JobCore {
+	return &scheduler.JobCore{
+		Id:         id,
+		Name:       req.Name,
+		CronRule:   req.CronRule,
+		TimeZone:   req.TimeZone,
+		RunnerName: req.RunnerName,
+		RunnerArgs: req.RunnerArgs,
+		Timeout:    time.Duration(req.Timeout) * time.Second,
+
+		ConcurrencyPolicy: req.Policy,
+		Retry:             req.Retry.policy(),
+		Misfire:           req.Misfire.policy(),
+	}
+}
+
+// 错过执行的处理策略的JSON表示，时间单位为秒
+type misfireView struct {
+	Policy       string `json:"policy"`
+	Limit        int    `json:"limit"`
+	GraceSeconds int64  `json:"grace_seconds"`
+}
+
+func newMisfireView(p *scheduler.MisfirePolicy) misfireView {
+	return misfireView{Policy: p.Policy, Limit: p.Limit, GraceSeconds: int64(p.Grace / time.Second)}
+}
+
+func (v *misfireView) policy() scheduler.MisfirePolicy {
+	return scheduler.MisfirePolicy{Policy: v.Policy, Limit: v.Limit, Grace: time.Duration(v.GraceSeconds) * time.Second}
+}
+
+// 重试策略的JSON表示，时间单位为秒
+type retryView struct {
+	MaxAttempts     int      `json:"max_attempts"`
+	Backoff         string   `json:"backoff"`
+	DelaySeconds    int64    `json:"delay_seconds"`
+	MaxDelaySeconds int64    `json:"max_delay_seconds"`
+	Jitter          float64  `json:"jitter"`
+	On              []string `json:"on"`
+}
+
+func newRetryView(p *scheduler.RetryPolicy) retryView {
+	return retryView{
+		MaxAttempts:     p.MaxAttempts,
+		Backoff:         p.Backoff,
+		DelaySeconds:    int64(p.Delay / time.Second),
+		MaxDelaySeconds: int64(p.MaxDelay / time.Second),
+		Jitter:          p.Jitter,
+		On:              p.On,
+	}
+}
+
+func (v *retryView) policy() scheduler.RetryPolicy {
+	return scheduler.RetryPolicy{
+		MaxAttempts: v.MaxAttempts,
+		Backoff:     v.Backoff,
+		Delay:       time.Duration(v.DelaySeconds) * time.Second,
+		MaxDelay:    time.Duration(v.MaxDelaySeconds) * time.Second,
+		Jitter:      v.Jitter,
+		On:          v.On,
+	}
+}
+
+// 执行记录的JSON表示
+type resultView struct {
+	Id            string     `json:"id"`
+	JobId         string     `json:"job_id"`
+	JobName       string     `json:"job_name"`
+	JobCronRule   string     `json:"job_cron_rule"`
+	JobRunnerName string     `json:"job_runner_name"`
+	JobRunnerArgs string     `json:"job_runner_args"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       *time.Time `json:"end_time,omitempty"` // 执行尚未结束时省略
+	ExecuteState  string     `json:"execute_state"`
+	Log           string     `json:"log"`
+	Attempt       int        `json:"attempt"`
+	OriginId      string     `json:"origin_id"`
+	Manual        bool       `json:"manual"`
+	TriggeredBy   string     `json:"triggered_by,omitempty"`
+	Owner         string     `json:"owner,omitempty"`
+	HeartbeatTime *time.Time `json:"heartbeat_time,omitempty"` // 没有心跳时省略
+	QueueWaitMs   int64      `json:"queue_wait_ms"`            // 排队等待工作协程的时间（毫秒）
+}
+
+func newResultView(r *scheduler.Result) *resultView {
+	v := &resultView{
+		Id:            r.Id,
+		JobId:         r.JobId,
+		JobName:       r.JobName,
+		JobCronRule:   r.JobCronRule,
+		JobRunnerName: r.JobRunnerName,
+		JobRunnerArgs: r.JobRunnerArgs,
+		StartTime:     r.StartTime,
+		ExecuteState:  r.ExecuteState,
+		Log:           r.Log,
+		Attempt:       r.Attempt,
+		OriginId:      r.OriginId,
+		Manual:        r.Manual,
+		TriggeredBy:   r.TriggeredBy,
+		Owner:         r.Owner,
+		QueueWaitMs:   int64(r.QueueWait / time.Millisecond),
+	}
+	if !r.EndTime.IsZero() {
+		end := r.EndTime
+		v.EndTime = &end
+	}
+	if !r.HeartbeatTime.IsZero() {
+		heartbeat := r.HeartbeatTime
+		v.HeartbeatTime = &heartbeat
+	}
+	return v
+}
+
+type resultPageView struct {
+	Total   int           `json:"total"`
+	Results []*resultView `json:"results"`
+}
+
+// 执行统计的JSON表示
+type resultStatsView struct {
+	JobId           string         `json:"job_id"`
+	Total           int            `json:"total"`
+	Counts          map[string]int `json:"counts"`
+	LastStartTime   *time.Time     `json:"last_start_time,omitempty"`
+	LastSuccessTime *time.Time     `json:"last_success_time,omitempty"`
+	LastFailTime    *time.Time     `json:"last_fail_time,omitempty"`
+	AvgDurationMs   int64          `json:"avg_duration_ms"`
+}
+
+func newResultStatsView(stats *scheduler.ResultStats) *resultStatsView {
+	nonZero := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	return &resultStatsView{
+		JobId:           stats.JobId,
+		Total:           stats.Total,
+		Counts:          stats.Counts,
+		LastStartTime:   nonZero(stats.LastStartTime),
+		LastSuccessTime: nonZero(stats.LastSuccessTime),
+		LastFailTime:    nonZero(stats.LastFailTime),
+		AvgDurationMs:   int64(stats.AvgDuration / time.Millisecond),
+	}
+}
+
+// 手动触发作业的请求体，可省略
+type triggerRequest struct {
+	RunnerArgs  *string `json:"runner_args"`  // 覆盖作业的爬虫参数，省略时使用作业本身的参数
+	TriggeredBy string  `json:"triggered_by"` // 触发人，省略时记录请求的来源地址
+}
+
+type triggerView struct {
+	ResultId string `json:"result_id,omitempty"` // 执行记录ID，排队等待时省略
+	Queued   bool   `json:"queued"`              // 是否按并发策略排队等待上一次执行结束
+}
+
+type schedulerView struct {
+	Running bool `json:"running"`
+}
+
+// 执行器快照的JSON表示
+type executorView struct {
+	GlobalLimit    int            `json:"global_limit"`    // 同时执行的作业总数上限，0表示不限制
+	RunnerLimits   map[string]int `json:"runner_limits"`   // 爬虫名称 -> 同时执行的数量上限
+	Running        int            `json:"running"`         // 占用工作协程的执行数量
+	RunningRunners map[string]int `json:"running_runners"` // 爬虫名称 -> 占用工作协程的执行数量
+	Pending        []*pendingView `json:"pending"`         // 排队等待工作协程的执行，按入队先后排列
+}
+
+type pendingView struct {
+	ResultId    string    `json:"result_id"`
+	JobId       string    `json:"job_id"`
+	JobName     string    `json:"job_name"`
+	RunnerName  string    `json:"runner_name"`
+	EnqueueTime time.Time `json:"enqueue_time"`
+	WaitMs      int64     `json:"wait_ms"`
+}
+
+func newExecutorView(snapshot *scheduler.ExecutorSnapshot) *executorView {
+	v := &executorView{
+		GlobalLimit:    snapshot.Limits.Global,
+		RunnerLimits:   snapshot.Limits.Runners,
+		Running:        snapshot.Running,
+		RunningRunners: snapshot.RunningRunners,
+		Pending:        []*pendingView{},
+	}
+	for _, p := range snapshot.Pending {
+		v.Pending = append(v.Pending, &pendingView{
+			ResultId:    p.ResultId,
+			JobId:       p.JobId,
+			JobName:     p.JobName,
+			RunnerName:  p.RunnerName,
+			EnqueueTime: p.EnqueueTime,
+			WaitMs:      int64(p.Wait / time.Millisecond),
+		})
+	}
+	return v
+}
+
+type errorView struct {
+	Error    string         `json:"error"`
+	Cron     *cronErrorView `json:"cron,omitempty"`      // 调度规则解析失败时的出错位置
+	ResultId string         `json:"result_id,omitempty"` // 手动触发被跳过时SKIPPED执行记录的ID
+}
+
+type cronErrorView struct {
+	Field    string `json:"field,omitempty"`
+	Index    int    `json:"index"`
+	Position int    `json:"position"`
+	Value    string `json:"value,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// err为调度规则解析失败时返回出错位置，否则返回nil
+func newCronErrorView(err error) *cronErrorView {
+	e, ok := err.(*scheduler.CronParseError)
+	if !ok {
+		return nil
+	}
+	return &cronErrorView{Field: e.Field, Index: e.Index, Position: e.Position, Value: e.Value, Reason: e.Reason}
+}
+
+// 返回指定调度器的REST接口处理器
+func NewHandler(s *scheduler.Scheduler) http.Handler {
+	h := &handler{s: s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", h.handleJobs)
+	mux.HandleFunc("/jobs/", h.handleJob)
+	mux.HandleFunc("/scheduler", h.handleScheduler)
+	mux.HandleFunc("/scheduler/", h.handleSchedulerCMD)
+	mux.HandleFunc("/executor", h.handleExecutor)
+	mux.HandleFunc("/results", h.handleResults)
+	mux.HandleFunc("/runners", h.handleRunners)
+	mux.HandleFunc("/schedule", handleSchedule)
+	mux.HandleFunc("/openapi.yaml", handleOpenAPI)
+	return mux
+}
+
+// 作用于同一调度器的接口处理函数
+type handler struct {
+	s *scheduler.Scheduler
+}
+
+// GET /jobs，POST /jobs
+func (h *handler) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		views := []*jobView{}
+		for _, job := range h.s.Jobs() {
+			views = append(views, newJobView(job))
+		}
+		sort.Slice(views, func(i, j int) bool { return views[i].CreateTime.Before(views[j].CreateTime) })
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		var req jobRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+		defer cancel()
+		job, err := h.s.NewJob(ctx, req.jobCore(""))
+		writeJob(w, http.StatusCreated, job, err)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// GET|PUT|DELETE /jobs/{id}，POST /jobs/{id}/open|close|kill|trigger，GET /jobs/{id}/stats
+func (h *handler) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	id := parts[0]
+	if id == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, "接口不存在")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+	defer cancel()
+
+	if len(parts) == 2 && parts[1] == "stats" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		stats, err := h.s.GetResultStats(ctx, id)
+		if err != nil {
+			writeCMDError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newResultStatsView(stats))
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if parts[1] == "trigger" {
+			h.handleTrigger(ctx, w, r, id)
+			return
+		}
+		var job *scheduler.Job
+		var err error
+		switch parts[1] {
+		case "open":
+			job, err = h.s.OpenJob(ctx, id)
+		case "close":
+			job, err = h.s.CloseJob(ctx, id)
+		case "kill":
+			job, err = h.s.KillJob(ctx, id)
+			if err == nil && job == nil { // 作业已被删除，但执行仍在进行
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		default:
+			writeError(w, http.StatusNotFound, "接口不存在")
+			return
+		}
+		writeJob(w, http.StatusOK, job, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		for _, job := range h.s.Jobs() {
+			if job.Id == id {
+				writeJSON(w, http.StatusOK, newJobView(job))
+				return
+			}
+		}
+		writeCMDError(w, scheduler.ErrJobNotFound)
+	case http.MethodPut:
+		var req jobRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		job, err := h.s.UpdateJob(ctx, req.jobCore(id))
+		writeJob(w, http.StatusOK, job, err)
+	case http.MethodDelete:
+		_, err := h.s.DeleteJob(ctx, id)
+		if err != nil {
+			writeCMDError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// POST /jobs/{id}/trigger，请求体可省略，按并发策略被跳过时返回409及SKIPPED执行记录ID
+func (h *handler) handleTrigger(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	var req triggerRequest
+	if r.ContentLength != 0 && !readJSON(w, r, &req) {
+		return
+	}
+	if req.TriggeredBy == "" {
+		req.TriggeredBy = r.RemoteAddr
+	}
+	resultId, err := h.s.TriggerJob(ctx, id, &scheduler.Trigger{By: req.TriggeredBy,
+		RunnerArgs: req.RunnerArgs})
+	if err != nil {
+		writeCMDError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, &triggerView{ResultId: resultId, Queued: resultId == ""})
+}
+
+// GET /scheduler
+func (h *handler) handleScheduler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, &schedulerView{Running: h.s.IsRunning()})
+}
+
+// POST /scheduler/start|stop|reload
+func (h *handler) handleSchedulerCMD(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+	defer cancel()
+
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, "/scheduler/") {
+	case "start":
+		err = h.s.Start(ctx)
+	case "stop":
+		err = h.s.Stop(ctx)
+	case "reload":
+		err = h.s.Reload(ctx)
+	default:
+		writeError(w, http.StatusNotFound, "接口不存在")
+		return
+	}
+	if err != nil {
+		writeCMDError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &schedulerView{Running: h.s.IsRunning()})
+}
+
+// GET /executor
+func (h *handler) handleExecutor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	snapshot := h.s.Executor()
+	if snapshot == nil {
+		writeError(w, http.StatusServiceUnavailable, scheduler.ErrSchedulerClosed.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newExecutorView(snapshot))
+}
+
+// GET /results?job_id=&origin_id=&state=&from=&to=&offset=&limit=，时间格式为RFC3339，state可重复
+func (h *handler) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	params := r.URL.Query()
+	query := &scheduler.ResultQuery{JobId: params.Get("job_id"), OriginId: params.Get("origin_id"),
+		States: params["state"]}
+	var err error
+	for name, p := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if v := params.Get(name); v != "" {
+			if *p, err = time.Parse(time.RFC3339, v); err != nil {
+				writeError(w, http.StatusBadRequest, "参数"+name+"解析失败，"+err.Error())
+				return
+			}
+		}
+	}
+	for name, p := range map[string]*int{"offset": &query.Offset, "limit": &query.Limit} {
+		if v := params.Get(name); v != "" {
+			if *p, err = strconv.Atoi(v); err != nil {
+				writeError(w, http.StatusBadRequest, "参数"+name+"解析失败，"+err.Error())
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+	defer cancel()
+	results, total, err := h.s.QueryResults(ctx, query)
+	if err != nil {
+		writeCMDError(w, err)
+		return
+	}
+	page := &resultPageView{Total: total, Results: []*resultView{}}
+	for _, result := range results {
+		page.Results = append(page.Results, newResultView(result))
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /runners
+func (h *handler) handleRunners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	names := h.s.RunnerNames()
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+const (
+	defaultPreviewNum = 5
+	maxPreviewNum     = 100
+)
+
+type scheduleView struct {
+	Description string      `json:"description"`
+	Next        []time.Time `json:"next"`
+}
+
+// GET /schedule?rule=&time_zone=&seed=&n=&lang=，描述调度规则并预览接下来的n个执行时刻，seed为H的哈希种子
+func handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	params := r.URL.Query()
+	n := defaultPreviewNum
+	if v := params.Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxPreviewNum {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("参数n必须为1-%d的整数", maxPreviewNum))
+			return
+		}
+	}
+	schedule, err := scheduler.NewJobSchedule(params.Get("rule"), params.Get("time_zone"), params.Get("seed"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorView{Error: err.Error(), Cron: newCronErrorView(err)})
+		return
+	}
+	view := &scheduleView{Description: schedule.DescribeIn(params.Get("lang")),
+		Next: scheduler.NextN(schedule, time.Now(), n)}
+	if view.Next == nil {
+		view.Next = []time.Time{}
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// GET /openapi.yaml
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	_, _ = w.Write([]byte(openAPI))
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体解析失败，"+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logs.ErrorLogger.Printf("写入响应失败，%s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, &errorView{Error: msg})
+}
+
+func writeJob(w http.ResponseWriter, status int, job *scheduler.Job, err error) {
+	if err != nil {
+		writeCMDError(w, err)
+		return
+	}
+	writeJSON(w, status, newJobView(job))
+}
+
+// 将调度器指令错误映射为HTTP状态码
+func writeCMDError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch e := err.(type) {
+	case *scheduler.JobBuildError:
+		writeJSON(w, http.StatusBadRequest, &errorView{Error: err.Error(), Cron: newCronErrorView(e.Err)})
+		return
+	case *scheduler.JobSkippedError:
+		writeJSON(w, http.StatusConflict, &errorView{Error: err.Error(), ResultId: e.ResultId})
+		return
+	case *scheduler.DatabaseError:
+		status = http.StatusInternalServerError
+	default:
+		switch err {
+		case scheduler.ErrJobNotFound:
+			status = http.StatusNotFound
+		case scheduler.ErrJobAlreadyOpened, scheduler.ErrJobAlreadyClosed, scheduler.ErrJobNotExecuting,
+			scheduler.ErrSchedulerAlreadyRunning, scheduler.ErrSchedulerRunning:
+			status = http.StatusConflict
+		case scheduler.ErrSchedulerNotRunning, scheduler.ErrSchedulerClosed, scheduler.ErrStoreNotConfigured:
+			status = http.StatusServiceUnavailable
+		case context.DeadlineExceeded, context.Canceled:
+			status = http.StatusGatewayTimeout
+		}
+	}
+	writeError(w, status, err.Error())
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "不支持的请求方法")
+}
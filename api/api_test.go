@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/xnffdd/gospider/scheduler"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func do(t *testing.T, h http.Handler, method, path, body string, status int, v interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != status {
+		t.Fatalf("%s %s：期望状态码%d，实际%d，响应：%s", method, path, status, rec.Code, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s：响应解析失败，%s", method, path, err.Error())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	sched := scheduler.New(scheduler.Options{Store: scheduler.NewMemoryStore()})
+	defer sched.Close()
+	h := NewHandler(sched)
+
+	var e errorView
+	do(t, h, http.MethodPost, "/jobs", `{"name":"a","cron_rule":"0 0 0 1 1 *","runner_name":"WeiXinArticle"}`,
+		http.StatusServiceUnavailable, &e)
+
+	var s schedulerView
+	do(t, h, http.MethodPost, "/scheduler/start", "", http.StatusOK, &s)
+	if !s.Running {
+		t.Error("调度器启动后状态应为运行中")
+	}
+	do(t, h, http.MethodPost, "/scheduler/start", "", http.StatusConflict, &e)
+
+	do(t, h, http.MethodPost, "/jobs", `{"name":"a","cron_rule":"0 0 0 1 13 *","runner_name":"WeiXinArticle"}`,
+		http.StatusBadRequest, &e)
+	if e.Cron == nil || e.Cron.Field != "month" || e.Cron.Position != 8 {
+		t.Errorf("调度规则非法时应返回出错位置：%+v", e.Cron)
+	}
+	e = errorView{}
+	do(t, h, http.MethodPost, "/jobs", `{"name":"a","cron_rule":"0 0 0 1 1 *","runner_name":"NotExist"}`,
+		http.StatusBadRequest, &e)
+	if e.Cron != nil {
+		t.Errorf("爬虫名称非法时不应返回调度规则的出错位置：%+v", e.Cron)
+	}
+	do(t, h, http.MethodPost, "/jobs", `{"nmae":"a"}`, http.StatusBadRequest, &e)
+
+	var job jobView
+	do(t, h, http.MethodPost, "/jobs", `{"name":"a","cron_rule":"0 0 0 1 1 *","runner_name":"WeiXinArticle"}`,
+		http.StatusCreated, &job)
+	if job.Id == "" || job.Opened || job.Next != nil {
+		t.Errorf("新建作业不符合预期：%+v", job)
+	}
+
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/open", "", http.StatusOK, &job)
+	if !job.Opened || job.Next == nil {
+		t.Errorf("开启作业后应计算下一次执行时刻：%+v", job)
+	}
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/open", "", http.StatusConflict, &e)
+
+	do(t, h, http.MethodPut, "/jobs/"+job.Id, `{"name":"b","cron_rule":"0 0 0 1 1 *","runner_name":"WeiXinArticle"}`,
+		http.StatusOK, &job)
+	if job.Name != "b" {
+		t.Errorf("更新作业后名称应为b：%+v", job)
+	}
+
+	var jobs []jobView
+	do(t, h, http.MethodGet, "/jobs", "", http.StatusOK, &jobs)
+	if len(jobs) != 1 || jobs[0].Id != job.Id {
+		t.Errorf("作业列表不符合预期：%+v", jobs)
+	}
+	do(t, h, http.MethodGet, "/jobs/"+job.Id, "", http.StatusOK, &job)
+
+	var runners []string
+	do(t, h, http.MethodGet, "/runners", "", http.StatusOK, &runners)
+	if len(runners) == 0 {
+		t.Error("爬虫列表为空")
+	}
+
+	var page resultPageView
+	do(t, h, http.MethodGet, "/results?job_id="+job.Id+"&state=SUCCESS&state=FAIL&limit=5", "", http.StatusOK, &page)
+	if page.Total != 0 || len(page.Results) != 0 {
+		t.Errorf("执行记录应为空：%+v", page)
+	}
+	do(t, h, http.MethodGet, "/results?from=yesterday", "", http.StatusBadRequest, &e)
+	var stats resultStatsView
+	do(t, h, http.MethodGet, "/jobs/"+job.Id+"/stats", "", http.StatusOK, &stats)
+	if stats.JobId != job.Id || stats.Total != 0 {
+		t.Errorf("执行统计不符合预期：%+v", stats)
+	}
+
+	var trigger triggerView
+	do(t, h, http.MethodPost, "/jobs/not-exist/trigger", "", http.StatusNotFound, &e)
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/trigger", `{"runner_args":"rust","triggered_by":"admin"}`,
+		http.StatusAccepted, &trigger)
+	if trigger.ResultId == "" || trigger.Queued {
+		t.Errorf("手动触发响应不符合预期：%+v", trigger)
+	}
+
+	var executor executorView
+	do(t, h, http.MethodGet, "/executor", "", http.StatusOK, &executor)
+	if executor.GlobalLimit != 0 || executor.Pending == nil {
+		t.Errorf("执行器快照不符合预期：%+v", executor)
+	}
+
+	do(t, h, http.MethodDelete, "/jobs/"+job.Id, "", http.StatusNoContent, nil)
+	do(t, h, http.MethodDelete, "/jobs/"+job.Id, "", http.StatusNotFound, &e)
+	do(t, h, http.MethodGet, "/jobs/"+job.Id, "", http.StatusNotFound, &e)
+	do(t, h, http.MethodPatch, "/jobs", "", http.StatusMethodNotAllowed, &e)
+
+	do(t, h, http.MethodPost, "/scheduler/stop", "", http.StatusOK, &s)
+	if s.Running {
+		t.Error("调度器停止后状态应为未运行")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	sched := scheduler.New(scheduler.Options{Store: scheduler.NewMemoryStore()})
+	defer sched.Close()
+	h := NewHandler(sched)
+
+	var v scheduleView
+	do(t, h, http.MethodGet, "/schedule?rule=0+0+0+%3F+*+wed&n=3", "", http.StatusOK, &v)
+	if v.Description != "每周三 00:00:00" || len(v.Next) != 3 || v.Next[0].Weekday() != time.Wednesday {
+		t.Errorf("调度规则预览不符合预期：%+v", v)
+	}
+	do(t, h, http.MethodGet, "/schedule?rule=%40every+1h&lang=en", "", http.StatusOK, &v)
+	if v.Description != "every 1h0m0s" || len(v.Next) != 5 {
+		t.Errorf("调度规则预览不符合预期：%+v", v)
+	}
+
+	var e errorView
+	do(t, h, http.MethodGet, "/schedule?rule=0+0+0+1+13+*", "", http.StatusBadRequest, &e)
+	do(t, h, http.MethodGet, "/schedule?rule=%40daily&n=0", "", http.StatusBadRequest, &e)
+}
+
+func TestTriggerSkipped(t *testing.T) {
+	release := make(chan struct{})
+	block := func(ctx context.Context, args string) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	sched := scheduler.New(scheduler.Options{Store: scheduler.NewMemoryStore(),
+		Runners: scheduler.RunnerMap{"Block": block}})
+	defer sched.Close()
+	defer close(release)
+	h := NewHandler(sched)
+
+	var runners []string
+	do(t, h, http.MethodGet, "/runners", "", http.StatusOK, &runners)
+	if len(runners) != 1 || runners[0] != "Block" {
+		t.Errorf("爬虫列表应来自调度器选项：%v", runners)
+	}
+
+	var s schedulerView
+	do(t, h, http.MethodPost, "/scheduler/start", "", http.StatusOK, &s)
+	var job jobView
+	do(t, h, http.MethodPost, "/jobs", `{"name":"a","cron_rule":"0 0 0 1 1 *","runner_name":"Block",`+
+		`"concurrency_policy":"SKIP"}`, http.StatusCreated, &job)
+
+	var trigger triggerView
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/trigger", "", http.StatusAccepted, &trigger)
+	var e errorView
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/trigger", "", http.StatusConflict, &e)
+	if e.ResultId == "" || e.ResultId == trigger.ResultId {
+		t.Errorf("被跳过的手动触发应返回SKIPPED执行记录ID：%+v", e)
+	}
+
+	sched.Close()
+	do(t, h, http.MethodPost, "/jobs/"+job.Id+"/trigger", "", http.StatusServiceUnavailable, &e)
+}
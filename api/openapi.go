@@ -0,0 +1,363 @@
+package api
+
+// OpenAPI 3.0接口描述，通过GET /openapi.yaml获取，修改接口时需同步修改
+const openAPI = `openapi: 3.0.3
+info:
+  title: gospider
+  description: 爬虫调度系统的作业管理和调度器控制接口
+  version: "1.0"
+paths:
+  /jobs:
+    get:
+      summary: 列出调度中的作业
+      responses:
+        "200":
+          description: 作业列表，按创建时间升序
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Job"}
+    post:
+      summary: 新建作业
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/JobRequest"}
+      responses:
+        "201": {$ref: "#/components/responses/Job"}
+        "400": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /jobs/{id}:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    get:
+      summary: 查询作业
+      responses:
+        "200": {$ref: "#/components/responses/Job"}
+        "404": {$ref: "#/components/responses/Error"}
+    put:
+      summary: 更新作业
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/JobRequest"}
+      responses:
+        "200": {$ref: "#/components/responses/Job"}
+        "400": {$ref: "#/components/responses/Error"}
+        "404": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+    delete:
+      summary: 删除作业
+      responses:
+        "204": {description: 删除成功}
+        "404": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /jobs/{id}/open:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    post:
+      summary: 开启作业
+      responses:
+        "200": {$ref: "#/components/responses/Job"}
+        "404": {$ref: "#/components/responses/Error"}
+        "409": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /jobs/{id}/close:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    post:
+      summary: 关闭作业
+      responses:
+        "200": {$ref: "#/components/responses/Job"}
+        "404": {$ref: "#/components/responses/Error"}
+        "409": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /jobs/{id}/kill:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    post:
+      summary: 终止作业正在进行的全部执行，被终止的执行记录状态为CANCELLED
+      responses:
+        "200": {$ref: "#/components/responses/Job"}
+        "204": {description: 作业已被删除，其执行已终止}
+        "409": {$ref: "#/components/responses/Error"}
+  /jobs/{id}/trigger:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    post:
+      summary: 立即执行作业，遵循作业的并发策略，不影响下一次执行时刻
+      requestBody:
+        required: false
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                runner_args: {type: string, description: 覆盖作业的爬虫参数，省略时使用作业本身的参数}
+                triggered_by: {type: string, description: 触发人，省略时记录请求的来源地址}
+      responses:
+        "202":
+          description: 已触发
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  result_id: {type: string, description: 执行记录ID，排队等待时省略}
+                  queued: {type: boolean}
+        "404": {$ref: "#/components/responses/Error"}
+        "409":
+          description: 按并发策略被跳过，result_id为SKIPPED执行记录的ID
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /jobs/{id}/stats:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    get:
+      summary: 统计作业的执行情况
+      responses:
+        "200":
+          description: 执行统计
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/ResultStats"}
+  /results:
+    get:
+      summary: 分页查询执行记录，按开始执行时间倒序
+      parameters:
+        - {name: job_id, in: query, schema: {type: string}}
+        - {name: origin_id, in: query, description: 首次尝试的执行记录ID，查询同一次执行的全部尝试, schema: {type: string}}
+        - {name: state, in: query, description: 执行状态，可重复, schema: {type: array, items: {type: string}}, explode: true}
+        - {name: from, in: query, description: 开始执行时间下限（包含）, schema: {type: string, format: date-time}}
+        - {name: to, in: query, description: 开始执行时间上限（不包含）, schema: {type: string, format: date-time}}
+        - {name: offset, in: query, schema: {type: integer, default: 0}}
+        - {name: limit, in: query, schema: {type: integer, default: 20, maximum: 1000}}
+      responses:
+        "200":
+          description: 执行记录分页
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  total: {type: integer}
+                  results:
+                    type: array
+                    items: {$ref: "#/components/schemas/Result"}
+        "400": {$ref: "#/components/responses/Error"}
+  /schedule:
+    get:
+      summary: 描述调度规则并预览接下来的执行时刻，用于填写作业前校验
+      parameters:
+        - {name: rule, in: query, required: true, schema: {type: string}}
+        - {name: time_zone, in: query, schema: {type: string}}
+        - {name: seed, in: query, description: H的哈希种子，传入作业ID可预览该作业的实际执行时刻, schema: {type: string}}
+        - {name: n, in: query, schema: {type: integer, default: 5, minimum: 1, maximum: 100}}
+        - {name: lang, in: query, description: 描述的语言, schema: {type: string, enum: [zh, en], default: zh}}
+      responses:
+        "200":
+          description: 调度规则的描述和预览
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  description: {type: string, example: 每周三 00:00:00}
+                  next:
+                    type: array
+                    items: {type: string, format: date-time}
+        "400": {$ref: "#/components/responses/Error"}
+  /scheduler:
+    get:
+      summary: 查询调度器运行状态
+      responses:
+        "200": {$ref: "#/components/responses/Scheduler"}
+  /scheduler/start:
+    post:
+      summary: 启动调度器
+      responses:
+        "200": {$ref: "#/components/responses/Scheduler"}
+        "409": {$ref: "#/components/responses/Error"}
+        "503": {$ref: "#/components/responses/Error"}
+  /scheduler/stop:
+    post:
+      summary: 停止调度器
+      responses:
+        "200": {$ref: "#/components/responses/Scheduler"}
+        "503": {$ref: "#/components/responses/Error"}
+  /scheduler/reload:
+    post:
+      summary: 重载调度器（从数据库重新加载作业），未启动时同时启动
+      responses:
+        "200": {$ref: "#/components/responses/Scheduler"}
+        "503": {$ref: "#/components/responses/Error"}
+  /executor:
+    get:
+      summary: 查询工作协程限制、占用情况及排队等待工作协程的执行
+      responses:
+        "200":
+          description: 执行器快照
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Executor"}
+        "503": {$ref: "#/components/responses/Error"}
+  /runners:
+    get:
+      summary: 列出全部爬虫名称
+      responses:
+        "200":
+          description: 爬虫名称列表
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {type: string}
+components:
+  schemas:
+    JobRequest:
+      type: object
+      required: [cron_rule, runner_name]
+      properties:
+        name: {type: string}
+        cron_rule: {type: string, example: "0 0 0 ? * wed", description: 5、6或7个字段（分钟起始、秒起始、带年份）的Cron表达式，字段可用H（按作业ID哈希）或R（随机）分散执行时刻、@daily等预定义规则或@every 1h30m固定间隔，可用CRON_TZ=或TZ=前缀指定时区，优先于time_zone}
+        time_zone: {type: string, example: Asia/Shanghai, description: 调度规则的IANA时区，省略时为服务器时区}
+        runner_name: {type: string, example: WeiXinArticle}
+        runner_args: {type: string}
+        timeout_seconds: {type: integer, minimum: 0, description: 单次执行超时时间（秒），0表示不限制}
+        concurrency_policy:
+          type: string
+          enum: [ALLOW, SKIP, QUEUE, REPLACE]
+          default: ALLOW
+          description: 上一次执行尚未结束时的处理方式：并行执行、跳过、排队（最多一次）、终止上一次执行
+        retry: {$ref: "#/components/schemas/RetryPolicy"}
+        misfire: {$ref: "#/components/schemas/MisfirePolicy"}
+    Job:
+      type: object
+      properties:
+        id: {type: string}
+        name: {type: string}
+        cron_rule: {type: string}
+        time_zone: {type: string}
+        runner_name: {type: string}
+        runner_args: {type: string}
+        timeout_seconds: {type: integer}
+        concurrency_policy: {type: string, enum: [ALLOW, SKIP, QUEUE, REPLACE]}
+        retry: {$ref: "#/components/schemas/RetryPolicy"}
+        misfire: {$ref: "#/components/schemas/MisfirePolicy"}
+        opened: {type: boolean}
+        create_time: {type: string, format: date-time}
+        update_time: {type: string, format: date-time}
+        next: {type: string, format: date-time, description: 下一次执行时刻，未调度时省略}
+    RetryPolicy:
+      type: object
+      description: 失败后的重试策略，每次尝试单独记录一条执行记录，被终止的执行不重试
+      properties:
+        max_attempts: {type: integer, minimum: 0, description: 最大尝试次数（含首次执行），<=1表示不重试}
+        backoff: {type: string, enum: [FIXED, EXPONENTIAL], default: FIXED}
+        delay_seconds: {type: integer, minimum: 0, description: 首次重试前的等待时间（秒）}
+        max_delay_seconds: {type: integer, minimum: 0, description: 等待时间上限（秒），0表示不限制}
+        jitter: {type: number, minimum: 0, maximum: 1, description: 随机抖动比例}
+        on:
+          type: array
+          items: {type: string, enum: [ERROR, TEMPORARY, TIMEOUT, PANIC]}
+          description: 需要重试的错误类别，为空表示全部失败都重试
+    MisfirePolicy:
+      type: object
+      description: 停机、调度器停止或定时器严重延迟导致错过执行时刻后的处理策略
+      properties:
+        policy:
+          type: string
+          enum: [IGNORE, ONCE, ALL]
+          default: IGNORE
+          description: 忽略、合并为一次立即执行、逐个补执行（最多limit次，上一次执行结束后依次执行）
+        limit: {type: integer, description: ALL方式最多补执行的次数，<=0表示默认10次}
+        grace_seconds: {type: integer, description: 宽限时间（秒），迟到不超过宽限时间的执行视为按时执行，<=0表示默认60秒}
+    Result:
+      type: object
+      properties:
+        id: {type: string}
+        job_id: {type: string}
+        job_name: {type: string}
+        job_cron_rule: {type: string}
+        job_runner_name: {type: string}
+        job_runner_args: {type: string}
+        start_time: {type: string, format: date-time}
+        end_time: {type: string, format: date-time, description: 执行尚未结束时省略}
+        execute_state: {type: string, enum: [SUCCESS, FAIL, RUNNING, TIMEOUT, CANCELLED, INTERRUPTED, ABANDONED, SKIPPED]}
+        log: {type: string}
+        attempt: {type: integer, description: 第几次尝试，从1开始}
+        origin_id: {type: string, description: 首次尝试的执行记录ID，首次尝试为自身ID}
+        manual: {type: boolean, description: 是否手动触发}
+        triggered_by: {type: string, description: 手动触发人，定时执行时省略}
+        owner: {type: string, description: 执行所在的调度器实例ID，被跳过的执行省略}
+        heartbeat_time: {type: string, format: date-time, description: 执行中最近一次心跳时间，没有心跳时省略}
+        queue_wait_ms: {type: integer, description: 排队等待工作协程的时间（毫秒）}
+    ResultStats:
+      type: object
+      properties:
+        job_id: {type: string}
+        total: {type: integer}
+        counts: {type: object, additionalProperties: {type: integer}, description: 执行状态 -> 次数}
+        last_start_time: {type: string, format: date-time}
+        last_success_time: {type: string, format: date-time}
+        last_fail_time: {type: string, format: date-time}
+        avg_duration_ms: {type: integer}
+    Scheduler:
+      type: object
+      properties:
+        running: {type: boolean}
+    Executor:
+      type: object
+      properties:
+        global_limit: {type: integer, description: 同时执行的作业总数上限，0表示不限制}
+        runner_limits: {type: object, additionalProperties: {type: integer}, description: 爬虫名称 -> 同时执行的数量上限}
+        running: {type: integer, description: 占用工作协程的执行数量}
+        running_runners: {type: object, additionalProperties: {type: integer}, description: 爬虫名称 -> 占用工作协程的执行数量}
+        pending:
+          type: array
+          description: 排队等待工作协程的执行，按入队先后排列
+          items:
+            type: object
+            properties:
+              result_id: {type: string, description: 开始执行后使用的执行记录ID}
+              job_id: {type: string}
+              job_name: {type: string}
+              runner_name: {type: string}
+              enqueue_time: {type: string, format: date-time}
+              wait_ms: {type: integer, description: 已等待的时间（毫秒）}
+    Error:
+      type: object
+      properties:
+        error: {type: string}
+        cron:
+          type: object
+          description: 调度规则解析失败时的出错位置，用于标出出错的字段
+          properties:
+            field: {type: string, enum: [second, minute, hour, day_of_month, month, day_of_week, year], description: 出错的字段，表达式整体出错时省略}
+            index: {type: integer, description: 出错的字段在表达式中的序号，从0开始，表达式整体出错时为-1}
+            position: {type: integer, description: 出错部分在表达式中的起始位置（字节偏移）}
+            value: {type: string, description: 出错的部分}
+            reason: {type: string}
+        result_id: {type: string, description: 手动触发被跳过时SKIPPED执行记录的ID}
+  responses:
+    Job:
+      description: 作业
+      content:
+        application/json:
+          schema: {$ref: "#/components/schemas/Job"}
+    Scheduler:
+      description: 调度器运行状态
+      content:
+        application/json:
+          schema: {$ref: "#/components/schemas/Scheduler"}
+    Error:
+      description: 错误，状态码400表示调度规则或爬虫名称非法，404作业不存在，409状态冲突，503调度器未启动或已关闭
+      content:
+        application/json:
+          schema: {$ref: "#/components/schemas/Error"}
+`
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/xnffdd/gospider/database"
+	"github.com/xnffdd/gospider/logs"
+	"github.com/xnffdd/gospider/scheduler"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"time"
+)
+
+// 持久化类型
+const (
+	mysqlStore  = "mysql"
+	sqliteStore = "sqlite"
+	memoryStore = "memory"
+)
+
+// 配置文件，database节由database.LoadConfig解析（支持环境变量覆盖）
+type config struct {
+	Store    string          `yaml:"store"`    // 持久化类型：mysql、sqlite、memory，默认mysql
+	SQLite   string          `yaml:"sqlite"`   // SQLite数据库文件路径，store为sqlite时有效
+	Database database.Config `yaml:"-"`        // MySQL连接配置，store为mysql时有效
+	Timezone string          `yaml:"timezone"` // 调度时区，IANA名称，例如Asia/Shanghai，默认服务器本地时区
+	HTTP     struct {
+		Listen string `yaml:"listen"` // HTTP监听地址
+	} `yaml:"http"`
+	Scheduler struct {
+		ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"` // 退出时等待正在进行的执行结束的最长时间，超时后中断执行
+		MaxWorkers      int            `yaml:"max_workers"`      // 同时执行的作业总数上限，0表示不限制
+		RunnerLimits    map[string]int `yaml:"runner_limits"`    // 按爬虫名称限制同时执行的数量
+	} `yaml:"scheduler"`
+	Log struct {
+		Level string `yaml:"level"` // 日志级别：info、error
+	} `yaml:"log"`
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{Store: mysqlStore, SQLite: "gospider.db"}
+	cfg.HTTP.Listen = ":8080"
+	cfg.Scheduler.ShutdownTimeout = 30 * time.Second
+	cfg.Log.Level = "info"
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败，%s", err.Error())
+		}
+		if err = yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件失败，文件：%s，%s", path, err.Error())
+		}
+	}
+
+	var err error
+	cfg.Database, err = database.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 应用日志级别和时区
+func (cfg *config) apply() error {
+	if err := logs.SetLevel(cfg.Log.Level); err != nil {
+		return err
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("加载时区失败，%s", err.Error())
+		}
+		time.Local = loc
+	}
+	return nil
+}
+
+// 按配置打开持久化，返回的关闭函数用于释放数据库连接
+func (cfg *config) openStore() (scheduler.Store, func(), error) {
+	switch cfg.Store {
+	case mysqlStore:
+		if err := database.InitMySQL(cfg.Database); err != nil {
+			return nil, nil, err
+		}
+		return scheduler.NewMySQLStore(database.MySQL), func() { _ = database.CloseMySQL() }, nil
+	case sqliteStore:
+		db, err := database.OpenSQLite(cfg.SQLite)
+		if err != nil {
+			return nil, nil, err
+		}
+		return scheduler.NewSQLiteStore(db), func() { closeDB(db) }, nil
+	case memoryStore:
+		return scheduler.NewMemoryStore(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的持久化类型：%s", cfg.Store)
+	}
+}
+
+// 按配置打开数据库连接并返回对应方言，内存持久化没有数据库
+func (cfg *config) openDB() (*sql.DB, string, error) {
+	switch cfg.Store {
+	case mysqlStore:
+		if err := database.InitMySQL(cfg.Database); err != nil {
+			return nil, "", err
+		}
+		return database.MySQL, database.MySQLDialect, nil
+	case sqliteStore:
+		db, err := database.OpenSQLite(cfg.SQLite)
+		if err != nil {
+			return nil, "", err
+		}
+		return db, database.SQLiteDialect, nil
+	default:
+		return nil, "", fmt.Errorf("持久化类型%s没有可迁移的数据库", cfg.Store)
+	}
+}
+
+func closeDB(db *sql.DB) {
+	if err := db.Close(); err != nil {
+		logs.ErrorLogger.Printf("数据库关闭失败，%s", err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/xnffdd/gospider/scheduler"
+	"strings"
+	"time"
+)
+
+// 描述调度规则并预览接下来的执行时刻
+func cron(args []string) error {
+	flags := flag.NewFlagSet("cron", flag.ContinueOnError)
+	timeZone := flags.String("tz", "", "调度规则的IANA时区，例如Asia/Shanghai，为空表示服务器时区")
+	n := flags.Int("n", 5, "预览的执行时刻个数")
+	seed := flags.String("seed", "", "H的哈希种子，通常为作业ID")
+	lang := flags.String("lang", scheduler.ChineseLang, "描述的语言：zh、en")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("用法：gospider cron [-tz 时区] [-seed 作业ID] [-n 个数] [-lang zh|en] <调度规则>")
+	}
+
+	schedule, err := scheduler.NewJobSchedule(strings.Join(flags.Args(), " "), *timeZone, *seed)
+	if err != nil {
+		return err
+	}
+	fmt.Println(schedule.DescribeIn(*lang))
+	for _, t := range scheduler.NextN(schedule, time.Now(), *n) {
+		fmt.Println(t.Format("2006-01-02 15:04:05 Mon -0700"))
+	}
+	return nil
+}
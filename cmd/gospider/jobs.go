@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/xnffdd/gospider/scheduler"
+	"github.com/xnffdd/gospider/spiders"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func jobs(cfg *config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少jobs子命令：list、add、update、delete、open、close")
+	}
+	if cfg.Store == memoryStore {
+		return fmt.Errorf("内存持久化不支持jobs命令")
+	}
+	store, closeStore, err := cfg.openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	switch args[0] {
+	case "list":
+		return listJobs(store)
+	case "add":
+		return addJob(store, args[1:])
+	case "update":
+		return updateJob(store, args[1:])
+	case "delete", "open", "close":
+		if len(args) != 2 {
+			return fmt.Errorf("用法：gospider jobs %s <作业ID>", args[0])
+		}
+		return changeJob(store, args[0], args[1])
+	default:
+		return fmt.Errorf("未知的jobs子命令：%s", args[0])
+	}
+}
+
+func listJobs(store scheduler.Store) error {
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		return err
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreateTime.Before(jobs[j].CreateTime) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\t名称\t调度规则\t爬虫\t参数\t超时\t并发策略\t尝试次数\t开启")
+	for _, job := range jobs {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\t%d\t%v\n",
+			job.Id, job.Name, job.CronRule, job.RunnerName, job.RunnerArgs, job.Timeout, job.ConcurrencyPolicy,
+			job.Retry.MaxAttempts, job.Opened)
+	}
+	return w.Flush()
+}
+
+// 校验调度规则和爬虫名称，与调度器构建作业的规则一致
+func validateJob(job *scheduler.Job) error {
+	if _, err := scheduler.NewJobSchedule(job.CronRule, job.TimeZone, job.Id); err != nil {
+		return err
+	}
+	if _, err := spiders.GetRunnerByName(job.RunnerName); err != nil {
+		return err
+	}
+	if err := scheduler.CheckConcurrencyPolicy(job.ConcurrencyPolicy); err != nil {
+		return err
+	}
+	if err := job.Retry.Check(); err != nil {
+		return err
+	}
+	return job.Misfire.Check()
+}
+
+// 注册重试策略参数，返回-retry-on参数的原始值
+func retryFlags(flags *flag.FlagSet, retry *scheduler.RetryPolicy) *string {
+	flags.IntVar(&retry.MaxAttempts, "retries", 0, "最大尝试次数（含首次执行），<=1表示不重试")
+	flags.StringVar(&retry.Backoff, "retry-backoff", "", "退避方式：FIXED、EXPONENTIAL")
+	flags.DurationVar(&retry.Delay, "retry-delay", 0, "首次重试前的等待时间，例如30s")
+	flags.DurationVar(&retry.MaxDelay, "retry-max-delay", 0, "重试等待时间上限，0表示不限制")
+	flags.Float64Var(&retry.Jitter, "retry-jitter", 0, "随机抖动比例，取值[0,1]")
+	return flags.String("retry-on", "", "需要重试的错误类别，逗号分隔：ERROR、TEMPORARY、TIMEOUT、PANIC，为空表示全部")
+}
+
+// 注册错过执行的处理策略参数
+func misfireFlags(flags *flag.FlagSet, misfire *scheduler.MisfirePolicy) {
+	flags.StringVar(&misfire.Policy, "misfire", "", "错过执行的处理方式：IGNORE、ONCE、ALL")
+	flags.IntVar(&misfire.Limit, "misfire-limit", 0, "ALL方式最多补执行的次数，<=0表示默认10次")
+	flags.DurationVar(&misfire.Grace, "misfire-grace", 0, "宽限时间，迟到不超过宽限时间的执行视为按时执行，<=0表示默认1m")
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func addJob(store scheduler.Store, args []string) error {
+	flags := flag.NewFlagSet("jobs add", flag.ContinueOnError)
+	name := flags.String("name", "", "作业名称")
+	cronRule := flags.String("cron", "", "调度规则")
+	timeZone := flags.String("tz", "", "调度规则的IANA时区，例如Asia/Shanghai，为空表示服务器时区")
+	runnerName := flags.String("runner", "", "爬虫名称")
+	runnerArgs := flags.String("args", "", "爬虫参数")
+	timeout := flags.Duration("timeout", 0, "单次执行超时时间，例如10m，0表示不限制")
+	policy := flags.String("concurrency", scheduler.AllowConcurrencyPolicy, "并发策略：ALLOW、SKIP、QUEUE、REPLACE")
+	retry := &scheduler.RetryPolicy{}
+	retryOn := retryFlags(flags, retry)
+	misfire := &scheduler.MisfirePolicy{}
+	misfireFlags(flags, misfire)
+	opened := flags.Bool("open", false, "是否开启")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	job := &scheduler.Job{}
+	job.Id = uuid.New().String()
+	job.Name = *name
+	job.CronRule = *cronRule
+	job.TimeZone = *timeZone
+	job.RunnerName = *runnerName
+	job.RunnerArgs = *runnerArgs
+	job.Timeout = *timeout
+	job.ConcurrencyPolicy = *policy
+	job.Retry = *retry
+	job.Retry.On = splitList(*retryOn)
+	job.Misfire = *misfire
+	job.Opened = *opened
+	if err := validateJob(job); err != nil {
+		return err
+	}
+	if _, err := store.InsertJob(job, time.Now()); err != nil {
+		return err
+	}
+	fmt.Println(job.Id)
+	return nil
+}
+
+func findJob(store scheduler.Store, id string) (*scheduler.Job, error) {
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.Id == id {
+			return job, nil
+		}
+	}
+	return nil, scheduler.ErrJobNotFound
+}
+
+func updateJob(store scheduler.Store, args []string) error {
+	flags := flag.NewFlagSet("jobs update", flag.ContinueOnError)
+	id := flags.String("id", "", "作业ID")
+	name := flags.String("name", "", "作业名称")
+	cronRule := flags.String("cron", "", "调度规则")
+	timeZone := flags.String("tz", "", "调度规则的IANA时区，例如Asia/Shanghai，为空表示服务器时区")
+	runnerName := flags.String("runner", "", "爬虫名称")
+	runnerArgs := flags.String("args", "", "爬虫参数")
+	timeout := flags.Duration("timeout", 0, "单次执行超时时间，例如10m，0表示不限制")
+	policy := flags.String("concurrency", "", "并发策略：ALLOW、SKIP、QUEUE、REPLACE")
+	retry := &scheduler.RetryPolicy{}
+	retryOn := retryFlags(flags, retry)
+	misfire := &scheduler.MisfirePolicy{}
+	misfireFlags(flags, misfire)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	job, err := findJob(store, *id)
+	if err != nil {
+		return err
+	}
+	flags.Visit(func(f *flag.Flag) { // 只更新显式传入的字段
+		switch f.Name {
+		case "name":
+			job.Name = *name
+		case "cron":
+			job.CronRule = *cronRule
+		case "tz":
+			job.TimeZone = *timeZone
+		case "runner":
+			job.RunnerName = *runnerName
+		case "args":
+			job.RunnerArgs = *runnerArgs
+		case "timeout":
+			job.Timeout = *timeout
+		case "concurrency":
+			job.ConcurrencyPolicy = *policy
+		case "retries":
+			job.Retry.MaxAttempts = retry.MaxAttempts
+		case "retry-backoff":
+			job.Retry.Backoff = retry.Backoff
+		case "retry-delay":
+			job.Retry.Delay = retry.Delay
+		case "retry-max-delay":
+			job.Retry.MaxDelay = retry.MaxDelay
+		case "retry-jitter":
+			job.Retry.Jitter = retry.Jitter
+		case "retry-on":
+			job.Retry.On = splitList(*retryOn)
+		case "misfire":
+			job.Misfire.Policy = misfire.Policy
+		case "misfire-limit":
+			job.Misfire.Limit = misfire.Limit
+		case "misfire-grace":
+			job.Misfire.Grace = misfire.Grace
+		}
+	})
+	if err = validateJob(job); err != nil {
+		return err
+	}
+	_, err = store.UpdateJob(job, time.Now())
+	return err
+}
+
+func changeJob(store scheduler.Store, action, id string) error {
+	job, err := findJob(store, id)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "delete":
+		_, err = store.DeleteJob(job, time.Now())
+	case "open":
+		if job.Opened {
+			return scheduler.ErrJobAlreadyOpened
+		}
+		job.Opened = true
+		_, err = store.UpdateJob(job, time.Now())
+	case "close":
+		if !job.Opened {
+			return scheduler.ErrJobAlreadyClosed
+		}
+		job.Opened = false
+		_, err = store.UpdateJob(job, time.Now())
+	}
+	return err
+}
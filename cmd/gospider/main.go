@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `gospider —— 爬虫调度系统
+
+用法：
+  gospider [-config 配置文件] <命令> [参数]
+
+命令：
+  serve                          启动调度器和HTTP服务，收到SIGINT/SIGTERM后退出
+  jobs list                      列出全部作业
+  jobs add -name -cron -runner -args [-tz] [-timeout] [-concurrency] [-retries ...] [-misfire ...] [-open]
+                                 新建作业
+  jobs update -id [-name] [-cron] [-tz] [-runner] [-args] [-timeout] [-concurrency] [-retries ...] [-misfire ...]
+                                 更新作业，重试参数：-retries -retry-backoff -retry-delay
+                                 -retry-max-delay -retry-jitter -retry-on
+                                 错过执行参数：-misfire -misfire-limit -misfire-grace
+  jobs delete <作业ID>           删除作业
+  jobs open <作业ID>             开启作业
+  jobs close <作业ID>            关闭作业
+  runners list                   列出全部爬虫
+  cron [-tz] [-seed] [-n] [-lang] <规则>
+                                 描述调度规则并预览接下来的执行时刻
+  migrate                        将数据库升级到最新版本
+
+jobs子命令直接修改数据库，运行中的调度器需重载后生效。
+配置文件默认读取环境变量GOSPIDER_CONFIG，数据库配置可被GOSPIDER_MYSQL_*环境变量覆盖。
+`
+
+func main() {
+	flags := flag.NewFlagSet("gospider", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configPath := flags.String("config", os.Getenv("GOSPIDER_CONFIG"), "配置文件路径（YAML）")
+	_ = flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err == nil {
+		err = cfg.apply()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "serve":
+		err = serve(cfg)
+	case "jobs":
+		err = jobs(cfg, args[1:])
+	case "runners":
+		err = runners(args[1:])
+	case "cron":
+		err = cron(args[1:])
+	case "migrate":
+		err = migrate(cfg)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"github.com/xnffdd/gospider/database"
+)
+
+func migrate(cfg *config) error {
+	db, dialect, err := cfg.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+
+	applied, err := database.Migrate(db, dialect)
+	if err != nil {
+		return err
+	}
+	version, err := database.CurrentSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("本次执行迁移%d个，当前数据库版本：%d\n", applied, version)
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"github.com/xnffdd/gospider/spiders"
+	"sort"
+)
+
+func runners(args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return fmt.Errorf("用法：gospider runners list")
+	}
+	names := spiders.GetRunnerNames()
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
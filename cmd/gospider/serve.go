@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/xnffdd/gospider/api"
+	"github.com/xnffdd/gospider/logs"
+	"github.com/xnffdd/gospider/scheduler"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func serve(cfg *config) error {
+	store, closeStore, err := cfg.openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	s := scheduler.New(scheduler.Options{
+		Store:   store,
+		Workers: scheduler.WorkerLimits{Global: cfg.Scheduler.MaxWorkers, Runners: cfg.Scheduler.RunnerLimits},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err = s.Start(ctx); err != nil {
+		s.Close()
+		return err
+	}
+
+	server := &http.Server{Addr: cfg.HTTP.Listen, Handler: newHandler(s)}
+	serverErr := make(chan error, 1)
+	go func() {
+		logs.InfoLogger.Printf("HTTP服务启动，监听地址：%s", cfg.HTTP.Listen)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		logs.InfoLogger.Printf("收到信号%v，开始退出", sig)
+	case err = <-serverErr:
+		logs.ErrorLogger.Printf("HTTP服务异常退出，%s", err.Error())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logs.ErrorLogger.Printf("HTTP服务关闭失败，%s", err.Error())
+	}
+	// 等待正在进行的执行结束，超时则中断并记录为INTERRUPTED，之后才关闭数据库
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Scheduler.ShutdownTimeout)
+	defer drainCancel()
+	if err := s.Shutdown(drainCtx); err != nil {
+		logs.ErrorLogger.Printf("调度器关闭失败，%s", err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("HTTP服务异常退出，%s", err.Error())
+	}
+	return nil
+}
+
+func newHandler(s *scheduler.Scheduler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", api.NewHandler(s))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.IsRunning() {
+			http.Error(w, "调度器未启动", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
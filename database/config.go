@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 数据库连接配置
+type Config struct {
+	DSN               string        `yaml:"dsn"`                 // MySQL数据源，例如"user:pass@tcp(localhost:3306)/gospider?charset=utf8&parseTime=True&loc=Local"
+	MaxOpenConns      int           `yaml:"max_open_conns"`      // 最大打开连接数，0表示不限制
+	MaxIdleConns      int           `yaml:"max_idle_conns"`      // 最大空闲连接数
+	ConnMaxLifetime   time.Duration `yaml:"conn_max_lifetime"`   // 连接最大存活时间，0表示不限制
+	ConnectTimeout    time.Duration `yaml:"connect_timeout"`     // 建立连接超时时间
+	PingRetries       int           `yaml:"ping_retries"`        // 启动时健康检查失败后的重试次数
+	PingRetryInterval time.Duration `yaml:"ping_retry_interval"` // 健康检查重试间隔
+	AutoMigrate       bool          `yaml:"auto_migrate"`        // 连接成功后是否自动将数据库升级到最新版本
+}
+
+// 环境变量名称，优先级高于配置文件
+const (
+	envDSN               = "GOSPIDER_MYSQL_DSN"
+	envMaxOpenConns      = "GOSPIDER_MYSQL_MAX_OPEN_CONNS"
+	envMaxIdleConns      = "GOSPIDER_MYSQL_MAX_IDLE_CONNS"
+	envConnMaxLifetime   = "GOSPIDER_MYSQL_CONN_MAX_LIFETIME"
+	envConnectTimeout    = "GOSPIDER_MYSQL_CONNECT_TIMEOUT"
+	envPingRetries       = "GOSPIDER_MYSQL_PING_RETRIES"
+	envPingRetryInterval = "GOSPIDER_MYSQL_PING_RETRY_INTERVAL"
+	envAutoMigrate       = "GOSPIDER_MYSQL_AUTO_MIGRATE"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		MaxOpenConns:      10,
+		MaxIdleConns:      2,
+		ConnMaxLifetime:   time.Hour,
+		ConnectTimeout:    5 * time.Second,
+		PingRetries:       3,
+		PingRetryInterval: 2 * time.Second,
+	}
+}
+
+// 加载配置：默认值 <- 配置文件的database节（path为空时跳过） <- 环境变量
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("读取配置文件失败，%s", err.Error())
+		}
+		file := struct {
+			Database *Config `yaml:"database"`
+		}{Database: &cfg}
+		if err = yaml.Unmarshal(data, &file); err != nil {
+			return cfg, fmt.Errorf("解析配置文件失败，文件：%s，%s", path, err.Error())
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadEnv() error {
+	if v, ok := os.LookupEnv(envDSN); ok {
+		cfg.DSN = v
+	}
+	if v, ok := os.LookupEnv(envAutoMigrate); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量%s解析布尔值失败，%s", envAutoMigrate, err.Error())
+		}
+		cfg.AutoMigrate = b
+	}
+	for name, p := range map[string]*int{
+		envMaxOpenConns: &cfg.MaxOpenConns,
+		envMaxIdleConns: &cfg.MaxIdleConns,
+		envPingRetries:  &cfg.PingRetries,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("环境变量%s解析整数失败，%s", name, err.Error())
+			}
+			*p = n
+		}
+	}
+	for name, p := range map[string]*time.Duration{
+		envConnMaxLifetime:   &cfg.ConnMaxLifetime,
+		envConnectTimeout:    &cfg.ConnectTimeout,
+		envPingRetryInterval: &cfg.PingRetryInterval,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("环境变量%s解析时长失败，%s", name, err.Error())
+			}
+			*p = d
+		}
+	}
+	return nil
+}
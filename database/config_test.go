@@ -0,0 +1,131 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var configEnvs = []string{
+	envDSN,
+	envMaxOpenConns,
+	envMaxIdleConns,
+	envConnMaxLifetime,
+	envConnectTimeout,
+	envPingRetries,
+	envPingRetryInterval,
+	envAutoMigrate,
+}
+
+// 清空所有配置环境变量后按env设置，返回恢复原环境变量的函数
+func setConfigEnv(t *testing.T, env map[string]string) func() {
+	saved := make(map[string]string)
+	for _, name := range configEnvs {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = v
+		}
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, v := range env {
+		if err := os.Setenv(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for _, name := range configEnvs {
+			if v, ok := saved[name]; ok {
+				_ = os.Setenv(name, v)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fileConfig := writeFile("gospider.yaml", `
+store: mysql
+database:
+  dsn: "file:pass@tcp(db:3306)/gospider"
+  max_open_conns: 20
+  conn_max_lifetime: 30m
+  auto_migrate: true
+`)
+	badConfig := writeFile("bad.yaml", "database: [dsn")
+
+	fromFile := DefaultConfig()
+	fromFile.DSN = "file:pass@tcp(db:3306)/gospider"
+	fromFile.MaxOpenConns = 20
+	fromFile.ConnMaxLifetime = 30 * time.Minute
+	fromFile.AutoMigrate = true
+
+	fromEnv := fromFile
+	fromEnv.DSN = "env:pass@tcp(db:3307)/gospider"
+	fromEnv.MaxOpenConns = 5
+	fromEnv.PingRetries = 0
+	fromEnv.ConnectTimeout = time.Second
+	fromEnv.AutoMigrate = false
+
+	tests := []struct {
+		name    string
+		path    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{name: "默认值", want: DefaultConfig()},
+		{name: "配置文件", path: fileConfig, want: fromFile},
+		{
+			name: "环境变量覆盖配置文件",
+			path: fileConfig,
+			env: map[string]string{
+				envDSN:            "env:pass@tcp(db:3307)/gospider",
+				envMaxOpenConns:   "5",
+				envPingRetries:    "0",
+				envConnectTimeout: "1s",
+				envAutoMigrate:    "false",
+			},
+			want: fromEnv,
+		},
+		{name: "配置文件不存在", path: filepath.Join(dir, "missing.yaml"), wantErr: true},
+		{name: "配置文件格式错误", path: badConfig, wantErr: true},
+		{name: "整数环境变量错误", env: map[string]string{envMaxOpenConns: "ten"}, wantErr: true},
+		{name: "时长环境变量错误", env: map[string]string{envConnectTimeout: "5"}, wantErr: true},
+		{name: "布尔环境变量错误", env: map[string]string{envAutoMigrate: "maybe"}, wantErr: true},
+	}
+	for _, test := range tests {
+		restore := setConfigEnv(t, test.env)
+		cfg, err := LoadConfig(test.path)
+		restore()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s：期望加载失败，实际成功：%+v", test.name, cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s：加载失败，%s", test.name, err.Error())
+			continue
+		}
+		if cfg != test.want {
+			t.Errorf("%s：期望配置%+v，实际%+v", test.name, test.want, cfg)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/xnffdd/gospider/logs"
+	"time"
+)
+
+// 数据库方言，与database/sql驱动名称一致
+const (
+	MySQLDialect  = "mysql"
+	SQLiteDialect = "sqlite3"
+)
+
+// 版本化的升级迁移，同一版本分别提供MySQL和SQLite的语句
+type Migration struct {
+	Version     int      // 版本号，从1开始连续递增
+	Description string   // 迁移说明
+	MySQL       []string // MySQL升级语句
+	SQLite      []string // SQLite升级语句
+}
+
+func (m *Migration) statements(dialect string) []string {
+	if dialect == SQLiteDialect {
+		return m.SQLite
+	}
+	return m.MySQL
+}
+
+// 全部迁移，只允许在末尾追加，不允许修改已发布的迁移
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "创建job表",
+		MySQL: []string{`create table if not exists job (
+			id          varchar(36)  not null,
+			ctime       datetime     not null,
+			utime       datetime     not null,
+			deleted     tinyint(1)   not null default 0,
+			name        varchar(255) not null default '',
+			cron_rule   varchar(255) not null default '',
+			opened      tinyint(1)   not null default 0,
+			runner_name varchar(255) not null default '',
+			runner_args text         not null,
+			primary key (id)
+		) engine=InnoDB default charset=utf8mb4`},
+		SQLite: []string{`create table if not exists job (
+			id          varchar(36)  primary key,
+			ctime       datetime     not null,
+			utime       datetime     not null,
+			deleted     boolean      not null default 0,
+			name        varchar(255) not null default '',
+			cron_rule   varchar(255) not null default '',
+			opened      boolean      not null default 0,
+			runner_name varchar(255) not null default '',
+			runner_args text         not null default ''
+		)`},
+	},
+	{
+		Version:     2,
+		Description: "创建job_result表",
+		MySQL: []string{`create table if not exists job_result (
+			id              varchar(36)  not null,
+			deleted         tinyint(1)   not null default 0,
+			ctime           datetime     not null,
+			utime           datetime     not null,
+			job_id          varchar(36)  not null,
+			job_name        varchar(255) not null default '',
+			job_cron_rule   varchar(255) not null default '',
+			job_runner_name varchar(255) not null default '',
+			job_runner_args text         not null,
+			start_time      datetime     not null,
+			end_time        datetime     null,
+			execute_state   varchar(16)  not null,
+			log             text         not null,
+			primary key (id)
+		) engine=InnoDB default charset=utf8mb4`},
+		SQLite: []string{`create table if not exists job_result (
+			id              varchar(36)  primary key,
+			deleted         boolean      not null default 0,
+			ctime           datetime     not null,
+			utime           datetime     not null,
+			job_id          varchar(36)  not null,
+			job_name        varchar(255) not null default '',
+			job_cron_rule   varchar(255) not null default '',
+			job_runner_name varchar(255) not null default '',
+			job_runner_args text         not null default '',
+			start_time      datetime     not null,
+			end_time        datetime,
+			execute_state   varchar(16)  not null,
+			log             text         not null default ''
+		)`},
+	},
+	{
+		Version:     3,
+		Description: "创建job(deleted)、job_result(job_id,start_time)索引",
+		MySQL: []string{
+			`create index idx_job_deleted on job(deleted)`,
+			`create index idx_job_result_job_id_start_time on job_result(job_id, start_time)`,
+		},
+		SQLite: []string{
+			`create index if not exists idx_job_deleted on job(deleted)`,
+			`create index if not exists idx_job_result_job_id_start_time on job_result(job_id, start_time)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "job表增加timeout字段",
+		MySQL:       []string{`alter table job add column timeout int not null default 0 comment '单次执行超时时间（秒），0表示不限制'`},
+		SQLite:      []string{`alter table job add column timeout int not null default 0`},
+	},
+	{
+		Version:     5,
+		Description: "job表增加concurrency_policy字段",
+		MySQL: []string{`alter table job add column concurrency_policy varchar(16) not null default 'ALLOW' ` +
+			`comment '并发策略：ALLOW、SKIP、QUEUE、REPLACE'`},
+		SQLite: []string{`alter table job add column concurrency_policy varchar(16) not null default 'ALLOW'`},
+	},
+	{
+		Version:     6,
+		Description: "job表增加retry_*重试策略字段，job_result表增加attempt、origin_id字段",
+		MySQL: []string{
+			`alter table job
+				add column retry_max_attempts int          not null default 0  comment '最大尝试次数（含首次执行），<=1表示不重试',
+				add column retry_backoff      varchar(16)  not null default '' comment '退避方式：FIXED、EXPONENTIAL',
+				add column retry_delay        int          not null default 0  comment '首次重试前的等待时间（秒）',
+				add column retry_max_delay    int          not null default 0  comment '等待时间上限（秒），0表示不限制',
+				add column retry_jitter       double       not null default 0  comment '随机抖动比例，取值[0,1]',
+				add column retry_on           varchar(255) not null default '' comment '需要重试的错误类别，逗号分隔，为空表示全部'`,
+			`alter table job_result
+				add column attempt   int         not null default 1  comment '第几次尝试，从1开始',
+				add column origin_id varchar(36) not null default '' comment '首次尝试的执行记录ID'`,
+			`update job_result set origin_id=id`,
+			`create index idx_job_result_origin_id on job_result(origin_id)`,
+		},
+		SQLite: []string{
+			`alter table job add column retry_max_attempts int not null default 0`,
+			`alter table job add column retry_backoff varchar(16) not null default ''`,
+			`alter table job add column retry_delay int not null default 0`,
+			`alter table job add column retry_max_delay int not null default 0`,
+			`alter table job add column retry_jitter double not null default 0`,
+			`alter table job add column retry_on varchar(255) not null default ''`,
+			`alter table job_result add column attempt int not null default 1`,
+			`alter table job_result add column origin_id varchar(36) not null default ''`,
+			`update job_result set origin_id=id`,
+			`create index if not exists idx_job_result_origin_id on job_result(origin_id)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "job_result表增加manual、triggered_by字段",
+		MySQL: []string{`alter table job_result
+			add column manual       tinyint(1)   not null default 0  comment '是否手动触发',
+			add column triggered_by varchar(255) not null default '' comment '手动触发人'`},
+		SQLite: []string{
+			`alter table job_result add column manual boolean not null default 0`,
+			`alter table job_result add column triggered_by varchar(255) not null default ''`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "job表增加misfire_*字段，job_result表增加(job_id,manual,start_time)索引",
+		MySQL: []string{
+			`alter table job
+				add column misfire_policy varchar(16) not null default 'IGNORE' comment '错过执行的处理方式：IGNORE、ONCE、ALL',
+				add column misfire_limit  int         not null default 0        comment 'ALL方式最多补执行的次数，<=0表示默认10次',
+				add column misfire_grace  int         not null default 0        comment '宽限时间（秒），<=0表示默认60秒'`,
+			`create index idx_job_result_job_id_manual_start_time on job_result(job_id, manual, start_time)`,
+		},
+		SQLite: []string{
+			`alter table job add column misfire_policy varchar(16) not null default 'IGNORE'`,
+			`alter table job add column misfire_limit int not null default 0`,
+			`alter table job add column misfire_grace int not null default 0`,
+			`create index if not exists idx_job_result_job_id_manual_start_time on job_result(job_id, manual, start_time)`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "job表增加time_zone字段",
+		MySQL: []string{`alter table job add column time_zone varchar(64) not null default '' ` +
+			`comment '调度规则的IANA时区，为空表示服务器时区'`},
+		SQLite: []string{`alter table job add column time_zone varchar(64) not null default ''`},
+	},
+	{
+		Version:     10,
+		Description: "job_result表增加owner、heartbeat_time字段及(execute_state,heartbeat_time)索引",
+		MySQL: []string{
+			`alter table job_result
+				add column owner          varchar(128) not null default '' comment '执行所在的调度器实例ID',
+				add column heartbeat_time datetime     null                comment '执行中最近一次心跳时间'`,
+			`create index idx_job_result_execute_state_heartbeat_time on job_result(execute_state, heartbeat_time)`,
+		},
+		SQLite: []string{
+			`alter table job_result add column owner varchar(128) not null default ''`,
+			`alter table job_result add column heartbeat_time datetime`,
+			`create index if not exists idx_job_result_execute_state_heartbeat_time on job_result(execute_state, heartbeat_time)`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "job_result表增加queue_wait字段",
+		MySQL: []string{`alter table job_result add column queue_wait int not null default 0 ` +
+			`comment '排队等待工作协程的时间（毫秒）'`},
+		SQLite: []string{`alter table job_result add column queue_wait int not null default 0`},
+	},
+}
+
+// 最新的数据库版本
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// 迁移期间持有的MySQL命名锁，防止多个实例同时启动时重复执行同一迁移
+const (
+	migrateLockName    = "gospider_migrate"
+	migrateLockTimeout = 60 // 等待锁的最长时间（秒）
+)
+
+// *sql.DB、*sql.Conn和*sql.Tx的公共方法
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// 当前的数据库版本，尚未迁移过时返回0
+func CurrentSchemaVersion(db *sql.DB) (int, error) {
+	return schemaVersion(context.Background(), db)
+}
+
+func schemaVersion(ctx context.Context, q querier) (int, error) {
+	if err := createSchemaVersionTable(ctx, q); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err := q.QueryRowContext(ctx, "select max(version) from schema_version").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func createSchemaVersionTable(ctx context.Context, q querier) error {
+	_, err := q.ExecContext(ctx, `create table if not exists schema_version (
+		version      int          not null primary key,
+		description  varchar(255) not null,
+		applied_time datetime     not null
+	)`)
+	return err
+}
+
+// 将数据库升级到最新版本，返回本次执行的迁移数量
+// SQLite的每个版本在一个事务中执行，失败时整体回滚；
+// MySQL的DDL会隐式提交无法回滚，改为在同一连接上持有命名锁，保证同一时刻只有一个实例在迁移
+func Migrate(db *sql.DB, dialect string) (applied int, err error) {
+	ctx := context.Background()
+	var q querier = db
+	if dialect == MySQLDialect {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("获取数据库连接失败，%s", err.Error())
+		}
+		defer conn.Close()
+		if err = lockMigrate(ctx, conn); err != nil {
+			return 0, err
+		}
+		defer unlockMigrate(ctx, conn)
+		q = conn
+	}
+	current, err := schemaVersion(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("查询数据库版本失败，%s", err.Error())
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if dialect == SQLiteDialect {
+			err = applyMigrationInTx(ctx, db, m, dialect)
+		} else {
+			err = applyMigration(ctx, q, m, dialect)
+		}
+		if err != nil {
+			return applied, err
+		}
+		logs.InfoLogger.Printf("数据库迁移成功，版本：%d（%s）", m.Version, m.Description)
+		applied++
+	}
+	return applied, nil
+}
+
+func applyMigrationInTx(ctx context.Context, db *sql.DB, m Migration, dialect string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("数据库迁移开启事务失败，版本：%d，%s", m.Version, err.Error())
+	}
+	if err = applyMigration(ctx, tx, m, dialect); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("数据库迁移提交事务失败，版本：%d，%s", m.Version, err.Error())
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, q querier, m Migration, dialect string) error {
+	for _, stmt := range m.statements(dialect) {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("数据库迁移失败，版本：%d（%s），%s", m.Version, m.Description, err.Error())
+		}
+	}
+	_, err := q.ExecContext(ctx, "insert into schema_version(version,description,applied_time) values(?,?,?)",
+		m.Version, m.Description, time.Now())
+	if err != nil {
+		return fmt.Errorf("记录数据库版本失败，版本：%d，%s", m.Version, err.Error())
+	}
+	return nil
+}
+
+// 获取迁移锁，GET_LOCK成功返回1，超时返回0，出错返回NULL
+func lockMigrate(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, "select get_lock(?, ?)", migrateLockName, migrateLockTimeout).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("获取数据库迁移锁失败，%s", err.Error())
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("获取数据库迁移锁超时，等待%d秒后仍被其他实例持有", migrateLockTimeout)
+	}
+	return nil
+}
+
+func unlockMigrate(ctx context.Context, conn *sql.Conn) {
+	var released sql.NullInt64
+	err := conn.QueryRowContext(ctx, "select release_lock(?)", migrateLockName).Scan(&released)
+	if err != nil {
+		logs.ErrorLogger.Printf("释放数据库迁移锁失败，%s", err.Error())
+	}
+}
+
+// 将MySQL升级到最新版本，需事先调用InitMySQL
+func MigrateMySQL() (applied int, err error) {
+	if MySQL == nil {
+		return 0, ErrMySQLNotInitialized
+	}
+	return Migrate(MySQL, MySQLDialect)
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	if !SQLiteSupported {
+		t.Skip("未启用cgo，跳过SQLite迁移测试")
+	}
+	dir, err := ioutil.TempDir("", "gospider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenSQLite(filepath.Join(dir, "gospider.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	version, err := CurrentSchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("期望数据库版本%d，实际%d", LatestSchemaVersion(), version)
+	}
+
+	applied, err := Migrate(db, SQLiteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 0 {
+		t.Errorf("重复迁移执行了%d个迁移", applied)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("迁移版本号必须从1开始连续递增，第%d个迁移的版本号为%d", i, m.Version)
+		}
+		if len(m.MySQL) == 0 || len(m.SQLite) == 0 {
+			t.Errorf("迁移版本%d缺少MySQL或SQLite语句", m.Version)
+		}
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	if !SQLiteSupported {
+		t.Skip("未启用cgo，跳过SQLite迁移测试")
+	}
+	dir, err := ioutil.TempDir("", "gospider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenSQLite(filepath.Join(dir, "gospider.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	saved := migrations
+	defer func() { migrations = saved }()
+	broken := Migration{
+		Version:     len(saved) + 1,
+		Description: "测试部分失败",
+		SQLite: []string{
+			`alter table job add column rollback_test int not null default 0`,
+			`alter table not_exists add column rollback_test int not null default 0`,
+		},
+	}
+	migrations = append(saved[:len(saved):len(saved)], broken)
+	if _, err = Migrate(db, SQLiteDialect); err == nil {
+		t.Fatal("期望迁移失败")
+	}
+	version, err := CurrentSchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(saved) {
+		t.Errorf("迁移失败后期望数据库版本%d，实际%d", len(saved), version)
+	}
+
+	// 失败的版本整体回滚，修正后重新迁移不会出现重复字段
+	broken.SQLite = broken.SQLite[:1]
+	migrations[len(migrations)-1] = broken
+	applied, err := Migrate(db, SQLiteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 1 {
+		t.Errorf("期望执行1个迁移，实际%d", applied)
+	}
+}
@@ -1,39 +1,107 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/xnffdd/gospider/logs"
+	"time"
 )
 
-const dsn string = "username:password@tcp(localhost:3306)/dbname?charset=utf8&parseTime=True&loc=Local"
-
+// MySQL连接池，调用InitMySQL成功后可用，此前为nil
 var MySQL *sql.DB
 
-func init() {
-	MySQL = connectMySQL(dsn)
-}
+var ErrMySQLNotInitialized = errors.New("MySQL数据库未初始化")
 
-func connectMySQL(conn string) *sql.DB {
-	var db, err = sql.Open("mysql", conn)
+// 按配置连接MySQL，并执行健康检查（失败时按配置重试）及自动迁移，成功后赋值给MySQL
+func InitMySQL(cfg Config) error {
+	if cfg.DSN == "" {
+		return fmt.Errorf("数据库连接失败，未配置数据源")
+	}
+	db, err := connectMySQL(cfg)
 	if err != nil {
 		msg := fmt.Sprintf("数据库连接失败，%s", err.Error())
 		logs.ErrorLogger.Println(msg)
-		panic(msg)
-	} else {
-		logs.InfoLogger.Println("数据库连接成功")
-		return db
+		return errors.New(msg)
+	}
+
+	for i := 0; ; i++ {
+		err = pingWithTimeout(db, cfg.ConnectTimeout)
+		if err == nil {
+			break
+		}
+		if i >= cfg.PingRetries {
+			_ = db.Close()
+			msg := fmt.Sprintf("数据库健康检查失败，已重试%d次，%s", i, err.Error())
+			logs.ErrorLogger.Println(msg)
+			return errors.New(msg)
+		}
+		logs.ErrorLogger.Printf("数据库健康检查失败，%v后重试，%s", cfg.PingRetryInterval, err.Error())
+		time.Sleep(cfg.PingRetryInterval)
+	}
+
+	if cfg.AutoMigrate {
+		if _, err = Migrate(db, MySQLDialect); err != nil {
+			_ = db.Close()
+			logs.ErrorLogger.Println(err.Error())
+			return err
+		}
+	}
+
+	MySQL = db
+	logs.InfoLogger.Println("数据库连接成功")
+	return nil
+}
+
+func pingWithTimeout(db *sql.DB, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
+	return db.PingContext(ctx)
 }
 
-func CloseMySQL() {
+func connectMySQL(cfg Config) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ConnectTimeout > 0 {
+		dsn.Timeout = cfg.ConnectTimeout
+	}
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, nil
+}
+
+// 健康检查
+func PingMySQL(ctx context.Context) error {
+	if MySQL == nil {
+		return ErrMySQLNotInitialized
+	}
+	return MySQL.PingContext(ctx)
+}
+
+func CloseMySQL() error {
+	if MySQL == nil {
+		return ErrMySQLNotInitialized
+	}
 	err := MySQL.Close()
 	if err != nil {
 		msg := fmt.Sprintf("数据库关闭失败，%s", err.Error())
 		logs.ErrorLogger.Println(msg)
-		panic(msg)
+		return errors.New(msg)
 	} else {
 		logs.InfoLogger.Println("数据库关闭成功")
+		return nil
 	}
 }
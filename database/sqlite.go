@@ -0,0 +1,29 @@
+//go:build cgo
+// +build cgo
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/xnffdd/gospider/logs"
+)
+
+// 是否支持SQLite，SQLite驱动依赖cgo
+const SQLiteSupported = true
+
+// 打开SQLite数据库文件（不存在时自动创建）并升级到最新版本
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_loc=auto&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("SQLite数据库打开失败，%s", err.Error())
+	}
+	db.SetMaxOpenConns(1) // SQLite不支持并发写
+	if _, err = Migrate(db, SQLiteDialect); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	logs.InfoLogger.Printf("SQLite数据库打开成功，文件：%s", path)
+	return db, nil
+}
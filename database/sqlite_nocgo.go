@@ -0,0 +1,19 @@
+//go:build !cgo
+// +build !cgo
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// 是否支持SQLite，SQLite驱动依赖cgo
+const SQLiteSupported = false
+
+var ErrSQLiteUnsupported = errors.New("当前程序未启用cgo编译，不支持SQLite数据库")
+
+// 未启用cgo时无法打开SQLite数据库
+func OpenSQLite(path string) (*sql.DB, error) {
+	return nil, ErrSQLiteUnsupported
+}
@@ -5,4 +5,6 @@ go 1.12
 require (
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/google/uuid v1.1.1
+	github.com/mattn/go-sqlite3 v1.14.22
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,9 +1,25 @@
 package logs
 
 import (
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 )
 
 var InfoLogger = log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
 var ErrorLogger = log.New(os.Stderr, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
+
+// 设置日志级别，info输出全部日志，error只输出错误日志
+func SetLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "", "info":
+		InfoLogger.SetOutput(os.Stdout)
+	case "error":
+		InfoLogger.SetOutput(ioutil.Discard)
+	default:
+		return fmt.Errorf("不支持的日志级别：%s", level)
+	}
+	return nil
+}
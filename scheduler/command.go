@@ -1,62 +1,323 @@
 package scheduler
 
+import (
+	"context"
+	"time"
+)
+
+// 调度器指令，经由通道发送给listen协程处理
+type command struct {
+	jobCore *JobCore            // 新建、更新作业指令携带的作业核心字段
+	jobId   string              // 删除、开启、关闭作业指令携带的作业ID
+	store   Store               // 更换持久化指令携带的持久化实现
+	trigger *Trigger            // 手动触发作业指令携带的触发参数
+	limits  *WorkerLimits       // 更新工作协程限制指令携带的限制
+	reply   chan *commandResult // 回传执行结果，带1个缓冲，listen协程回传时不会阻塞
+}
+
+// 调度器指令执行结果
+type commandResult struct {
+	job      *Job   // 指令执行后的作业快照，调度器指令为nil
+	store    Store  // 获取持久化指令回传的持久化实现
+	resultId string // 手动触发作业指令回传的执行记录ID
+	err      error  // 执行失败原因
+}
+
+// 回传执行结果，作业以副本形式回传，避免调用方与listen协程并发读写
+func (cmd *command) done(job *Job, err error) {
+	var snapshot *Job
+	if job != nil {
+		job2 := *job
+		snapshot = &job2
+	}
+	cmd.reply <- &commandResult{job: snapshot, err: err}
+}
+
+// 发送指令并等待执行结果，ctx超时或取消时返回ctx.Err()，调度器已关闭时返回ErrSchedulerClosed
+// 注意：指令一旦被listen协程接收，即使ctx随后超时也会继续执行完毕
+func (s *Scheduler) execCMD(ctx context.Context, ch chan *command, cmd *command) *commandResult {
+	cmd.reply = make(chan *commandResult, 1)
+	select {
+	case ch <- cmd:
+	case <-s.closed:
+		return &commandResult{err: ErrSchedulerClosed}
+	case <-ctx.Done():
+		return &commandResult{err: ctx.Err()}
+	}
+	select {
+	case r := <-cmd.reply:
+		return r
+	case <-ctx.Done():
+		return &commandResult{err: ctx.Err()}
+	}
+}
+
+func (s *Scheduler) sendCMD(ctx context.Context, ch chan *command, cmd *command) (*Job, error) {
+	r := s.execCMD(ctx, ch, cmd)
+	return r.job, r.err
+}
+
+// 获取调度器当前使用的持久化实现，未指定时使用database.MySQL
+func (s *Scheduler) currentStore(ctx context.Context) (Store, error) {
+	r := s.execCMD(ctx, s.getStore, &command{})
+	return r.store, r.err
+}
+
+// 启动调度器，阻塞调用，已启动时返回ErrSchedulerAlreadyRunning
+func (s *Scheduler) Start(ctx context.Context) error {
+	_, err := s.sendCMD(ctx, s.start, &command{})
+	return err
+}
+
+// 停止调度器，阻塞调用，未启动时返回ErrSchedulerNotRunning
+// 停止后不再启动新的定时执行，正在进行的执行不受影响
+func (s *Scheduler) Stop(ctx context.Context) error {
+	_, err := s.sendCMD(ctx, s.stop, &command{})
+	return err
+}
+
+// 重载调度器，阻塞调用，未启动时同时启动调度器
+func (s *Scheduler) Reload(ctx context.Context) error {
+	_, err := s.sendCMD(ctx, s.reload, &command{})
+	return err
+}
+
+// 关闭调度器，停止调度、中断正在进行的执行并退出listen协程，不等待执行结束，重复关闭时返回ErrSchedulerClosed
+// 返回前将仍未结束的执行记录标记为INTERRUPTED，并等待后台保存的执行记录写入完成
+// 关闭后调度器的全部指令均返回ErrSchedulerClosed
+func (s *Scheduler) Close() error {
+	_, err := s.sendCMD(context.Background(), s.quit, &command{})
+	return err
+}
+
+// 中断执行后等待其记录INTERRUPTED状态的最长时间
+const interruptGrace = 5 * time.Second
+
+// 优雅关闭调度器，阻塞调用：停止调度，放弃排队中的执行，等待正在进行的执行（包括重试）结束后关闭调度器
+// ctx超时或取消时中断未结束的执行，等待其记录INTERRUPTED状态（最多5秒）后关闭调度器并返回ctx.Err()
+// 返回后即可关闭数据库，仍未退出的执行的记录已标记为INTERRUPTED
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil && err != ErrSchedulerNotRunning {
+		return err
+	}
+	_, err := s.sendCMD(ctx, s.drain, &command{})
+	if err == ErrSchedulerClosed {
+		return err
+	}
+	if err != nil {
+		grace, cancel := context.WithTimeout(context.Background(), interruptGrace)
+		defer cancel()
+		if _, err := s.sendCMD(grace, s.interrupt, &command{}); err == nil {
+			_, _ = s.sendCMD(grace, s.drain, &command{})
+		}
+	}
+	if err2 := s.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// 调度器是否正在运行，阻塞调用，调度器已关闭时返回false
+func (s *Scheduler) IsRunning() bool {
+	select {
+	case s.isRunningSnapshot <- false:
+		return <-s.isRunningSnapshot
+	case <-s.closed:
+		return false
+	}
+}
+
+// 调度中的作业集快照，阻塞调用，调度器已关闭时返回nil
+func (s *Scheduler) Jobs() []*Job {
+	select {
+	case s.jobSnapshot <- nil:
+		return <-s.jobSnapshot
+	case <-s.closed:
+		return nil
+	}
+}
+
+// 按全部核心字段新建作业，忽略jobCore.Id，阻塞调用，成功时返回新建作业的快照（包含生成的作业ID）
+func (s *Scheduler) NewJob(ctx context.Context, jobCore *JobCore) (*Job, error) {
+	job := *jobCore
+	return s.sendCMD(ctx, s.new, &command{jobCore: &job})
+}
+
+// 删除作业，阻塞调用，成功时返回被删除作业的快照
+func (s *Scheduler) DeleteJob(ctx context.Context, jobId string) (*Job, error) {
+	return s.sendCMD(ctx, s.delete, &command{jobId: jobId})
+}
+
+// 按全部核心字段更新作业，作业由jobCore.Id指定，阻塞调用，成功时返回更新后作业的快照
+func (s *Scheduler) UpdateJob(ctx context.Context, jobCore *JobCore) (*Job, error) {
+	job := *jobCore
+	return s.sendCMD(ctx, s.update, &command{jobCore: &job})
+}
+
+// 开启作业，阻塞调用，成功时返回开启后作业的快照
+func (s *Scheduler) OpenJob(ctx context.Context, jobId string) (*Job, error) {
+	return s.sendCMD(ctx, s.open, &command{jobId: jobId})
+}
+
+// 关闭作业，阻塞调用，成功时返回关闭后作业的快照
+func (s *Scheduler) CloseJob(ctx context.Context, jobId string) (*Job, error) {
+	return s.sendCMD(ctx, s.close, &command{jobId: jobId})
+}
+
+// 终止作业正在进行的全部执行，阻塞调用，没有正在进行的执行时返回ErrJobNotExecuting
+// 被终止的执行记录状态为CANCELLED，作业本身的调度不受影响
+func (s *Scheduler) KillJob(ctx context.Context, jobId string) (*Job, error) {
+	return s.sendCMD(ctx, s.kill, &command{jobId: jobId})
+}
+
+// 手动触发作业的参数
+type Trigger struct {
+	By         string  // 触发人，记录到执行记录的triggered_by字段
+	RunnerArgs *string // 覆盖作业的爬虫参数，nil表示使用作业本身的参数
+}
+
+// 立即执行作业，阻塞调用，调度器未运行时返回ErrSchedulerNotRunning
+// 执行遵循作业的并发策略，返回执行记录ID（排队等待时为空），作业的下一次执行时刻不受影响
+// 被跳过时同时返回SKIPPED执行记录的ID和*JobSkippedError
+func (s *Scheduler) TriggerJob(ctx context.Context, jobId string, trigger *Trigger) (string, error) {
+	t := Trigger{}
+	if trigger != nil {
+		t = *trigger
+	}
+	r := s.execCMD(ctx, s.trigger, &command{jobId: jobId, trigger: &t})
+	return r.resultId, r.err
+}
+
+// 更换调度器使用的持久化实现，阻塞调用，仅允许在调度器停止时调用，否则返回ErrSchedulerRunning
+func (s *Scheduler) UseStore(ctx context.Context, store Store) error {
+	_, err := s.sendCMD(ctx, s.useStore, &command{store: store})
+	return err
+}
+
+// 以下函数均作用于默认调度器Default()
+
 func SendCMDStartScheduler() {
-	go func() { gs.start <- struct{}{} }()
+	go ExecCMDStartScheduler(context.Background())
 }
 
 func SendCMDStopScheduler() {
-	go func() { gs.stop <- struct{}{} }()
+	go ExecCMDStopScheduler(context.Background())
 }
 
 func SendCMDReloadScheduler() {
-	go func() { gs.reload <- struct{}{} }()
+	go ExecCMDReloadScheduler(context.Background())
 }
 
 func GetSchedulerIsRunningSnapshot() bool { // 阻塞调用
-	gs.isRunningSnapshot <- false
-	x := <-gs.isRunningSnapshot
-	return x
+	return Default().IsRunning()
 }
 
 func GetJobsSnapshot() []*Job { // 阻塞调用
-	gs.jobSnapshot <- nil
-	x := <-gs.jobSnapshot
-	return x
+	return Default().Jobs()
 }
 
 func SendCMDNewJob(name, cronRule, runnerName, runnerArgs string) {
-	go func() {
-		job := &JobCore{
-			Name:       name,
-			CronRule:   cronRule,
-			RunnerName: runnerName,
-			RunnerArgs: runnerArgs,
-		}
-		gs.new <- job
-	}()
+	go ExecCMDNewJob(context.Background(), name, cronRule, runnerName, runnerArgs)
 }
 
 func SendCMDDeleteJob(jobId string) {
-	go func() { gs.delete <- jobId }()
+	go ExecCMDDeleteJob(context.Background(), jobId)
 }
 
 func SendCMDUpdateJob(jobId, name, cronRule, runnerName, runnerArgs string) {
-	go func() {
-		job := &JobCore{
-			Id:         jobId,
-			Name:       name,
-			CronRule:   cronRule,
-			RunnerName: runnerName,
-			RunnerArgs: runnerArgs,
-		}
-		gs.update <- job
-	}()
+	go ExecCMDUpdateJob(context.Background(), jobId, name, cronRule, runnerName, runnerArgs)
 }
 
 func SendCMDOpenJob(jobId string) {
-	go func() { gs.open <- jobId }()
+	go ExecCMDOpenJob(context.Background(), jobId)
 }
 
 func SendCMDCloseJob(jobId string) {
-	go func() { gs.close <- jobId }()
+	go ExecCMDCloseJob(context.Background(), jobId)
+}
+
+func SendCMDKillJob(jobId string) {
+	go ExecCMDKillJob(context.Background(), jobId)
+}
+
+func SendCMDTriggerJob(jobId string, trigger *Trigger) {
+	go ExecCMDTriggerJob(context.Background(), jobId, trigger)
+}
+
+// 启动调度器，阻塞调用，已启动时返回ErrSchedulerAlreadyRunning
+func ExecCMDStartScheduler(ctx context.Context) error {
+	return Default().Start(ctx)
+}
+
+// 停止调度器，阻塞调用，未启动时返回ErrSchedulerNotRunning
+func ExecCMDStopScheduler(ctx context.Context) error {
+	return Default().Stop(ctx)
+}
+
+// 重载调度器，阻塞调用，未启动时同时启动调度器
+func ExecCMDReloadScheduler(ctx context.Context) error {
+	return Default().Reload(ctx)
+}
+
+// 新建作业，阻塞调用，成功时返回新建作业的快照（包含生成的作业ID）
+func ExecCMDNewJob(ctx context.Context, name, cronRule, runnerName, runnerArgs string) (*Job, error) {
+	job := &JobCore{
+		Name:       name,
+		CronRule:   cronRule,
+		RunnerName: runnerName,
+		RunnerArgs: runnerArgs,
+	}
+	return ExecCMDNewJobCore(ctx, job)
+}
+
+// 按全部核心字段新建作业，忽略jobCore.Id，阻塞调用
+func ExecCMDNewJobCore(ctx context.Context, jobCore *JobCore) (*Job, error) {
+	return Default().NewJob(ctx, jobCore)
+}
+
+// 删除作业，阻塞调用，成功时返回被删除作业的快照
+func ExecCMDDeleteJob(ctx context.Context, jobId string) (*Job, error) {
+	return Default().DeleteJob(ctx, jobId)
+}
+
+// 更新作业，阻塞调用，成功时返回更新后作业的快照
+func ExecCMDUpdateJob(ctx context.Context, jobId, name, cronRule, runnerName, runnerArgs string) (*Job, error) {
+	job := &JobCore{
+		Id:         jobId,
+		Name:       name,
+		CronRule:   cronRule,
+		RunnerName: runnerName,
+		RunnerArgs: runnerArgs,
+	}
+	return ExecCMDUpdateJobCore(ctx, job)
+}
+
+// 按全部核心字段更新作业，作业由jobCore.Id指定，阻塞调用
+func ExecCMDUpdateJobCore(ctx context.Context, jobCore *JobCore) (*Job, error) {
+	return Default().UpdateJob(ctx, jobCore)
+}
+
+// 开启作业，阻塞调用，成功时返回开启后作业的快照
+func ExecCMDOpenJob(ctx context.Context, jobId string) (*Job, error) {
+	return Default().OpenJob(ctx, jobId)
+}
+
+// 关闭作业，阻塞调用，成功时返回关闭后作业的快照
+func ExecCMDCloseJob(ctx context.Context, jobId string) (*Job, error) {
+	return Default().CloseJob(ctx, jobId)
+}
+
+// 终止作业正在进行的全部执行，阻塞调用，没有正在进行的执行时返回ErrJobNotExecuting
+func ExecCMDKillJob(ctx context.Context, jobId string) (*Job, error) {
+	return Default().KillJob(ctx, jobId)
+}
+
+// 立即执行作业，阻塞调用，参见Scheduler.TriggerJob
+func ExecCMDTriggerJob(ctx context.Context, jobId string, trigger *Trigger) (string, error) {
+	return Default().TriggerJob(ctx, jobId, trigger)
+}
+
+// 更换默认调度器使用的持久化实现，阻塞调用，仅允许在调度器停止时调用，否则返回ErrSchedulerRunning
+func ExecCMDUseStore(ctx context.Context, store Store) error {
+	return Default().UseStore(ctx, store)
 }
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/xnffdd/gospider/logs"
+)
+
+// 并发策略：作业到达执行时刻而上一次执行尚未结束时的处理方式，对应MySQL的job表concurrency_policy字段
+const (
+	AllowConcurrencyPolicy   = "ALLOW"   // 允许并行执行
+	SkipConcurrencyPolicy    = "SKIP"    // 跳过本次执行，记录SKIPPED执行记录
+	QueueConcurrencyPolicy   = "QUEUE"   // 排队等待上一次执行结束，最多排队一次，其余的跳过
+	ReplaceConcurrencyPolicy = "REPLACE" // 终止上一次执行，立即开始本次执行
+)
+
+// 校验并发策略，空字符串等同于ALLOW
+func CheckConcurrencyPolicy(policy string) error {
+	switch policy {
+	case "", AllowConcurrencyPolicy, SkipConcurrencyPolicy, QueueConcurrencyPolicy, ReplaceConcurrencyPolicy:
+		return nil
+	default:
+		return fmt.Errorf("并发策略非法，取值：%s，可选：%s、%s、%s、%s", policy,
+			AllowConcurrencyPolicy, SkipConcurrencyPolicy, QueueConcurrencyPolicy, ReplaceConcurrencyPolicy)
+	}
+}
+
+func concurrencyPolicyOrDefault(policy string) string {
+	if policy == "" {
+		return AllowConcurrencyPolicy
+	}
+	return policy
+}
+
+// 作业正在进行的执行数量
+func (s *Scheduler) executingCount(jobId string) int {
+	count := 0
+	for _, e := range s.executions {
+		if e.jobId == jobId {
+			count++
+		}
+	}
+	return count
+}
+
+// 按作业的并发策略执行作业，返回执行记录ID，排队等待时返回空，被跳过时返回SKIPPED执行记录的ID及*JobSkippedError
+func (s *Scheduler) dispatch(job *Job) (string, error) {
+	if s.executingCount(job.Id) == 0 {
+		return s.launch(job), nil
+	}
+
+	switch job.ConcurrencyPolicy {
+	case SkipConcurrencyPolicy:
+		return s.skip(job, "上一次执行尚未结束，跳过本次执行")
+	case QueueConcurrencyPolicy:
+		if _, ok := s.queued[job.Id]; ok {
+			return s.skip(job, "上一次执行尚未结束且已有排队中的执行，跳过本次执行")
+		}
+		logs.InfoLogger.Printf("上一次执行尚未结束，排队等待，作业ID：%s", job.Id)
+		s.queued[job.Id] = job
+		return "", nil
+	case ReplaceConcurrencyPolicy:
+		killed := s.processKillJobCMD(job.Id)
+		logs.InfoLogger.Printf("终止上一次执行%d个，作业ID：%s", killed, job.Id)
+		return s.launch(job), nil
+	default:
+		return s.launch(job), nil
+	}
+}
+
+// 作业执行结束后，若作业已没有正在进行的执行，则启动排队中的执行，没有排队中的执行时启动下一次补执行
+func (s *Scheduler) launchQueued(jobId string) {
+	if s.executingCount(jobId) > 0 {
+		return
+	}
+	job, ok := s.queued[jobId]
+	if !ok {
+		s.launchBacklog(jobId)
+		return
+	}
+	delete(s.queued, jobId)
+	logs.InfoLogger.Printf("启动排队中的执行，作业ID：%s", jobId)
+	s.launch(job)
+}
+
+// 跳过本次执行，并记录SKIPPED执行记录，返回执行记录ID及*JobSkippedError
+func (s *Scheduler) skip(job *Job, reason string) (string, error) {
+	logs.InfoLogger.Printf("%s，作业ID：%s", reason, job.Id)
+	result := newJobResult(job, s.store, s.clock, s.instanceId)
+	s.saveInBackground(result, func() error { return result.SaveSkipped(reason) })
+	return result.id, &JobSkippedError{ResultId: result.id, Reason: reason}
+}
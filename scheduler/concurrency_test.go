@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// 阻塞至release关闭或被取消的作业
+func blockingJob(policy string, release chan struct{}) *Job {
+	job := &Job{JobCore: JobCore{Id: "job-" + policy, ConcurrencyPolicy: policy}}
+	job.Runner = func(ctx context.Context, args string) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return job
+}
+
+func countStates(t *testing.T, store Store, jobId string) map[string]int {
+	stats, err := store.GetResultStats(jobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats.Counts
+}
+
+func TestDispatch(t *testing.T) {
+	release := make(chan struct{})
+
+	// SKIP：执行中再次到达执行时刻时跳过
+	s := newScheduler(NewMemoryStore(), RealClock)
+	job := blockingJob(SkipConcurrencyPolicy, release)
+	s.dispatch(job)
+	s.dispatch(job)
+	if n := s.executingCount(job.Id); n != 1 {
+		t.Errorf("SKIP：期望执行中1个，实际%d个", n)
+	}
+
+	// QUEUE：最多排队一次，上一次执行结束后启动
+	job = blockingJob(QueueConcurrencyPolicy, release)
+	s.dispatch(job)
+	s.dispatch(job)
+	s.dispatch(job)
+	if n := s.executingCount(job.Id); n != 1 || s.queued[job.Id] == nil {
+		t.Errorf("QUEUE：期望执行中1个且排队1个，实际执行中%d个", n)
+	}
+
+	// REPLACE：终止上一次执行
+	job = blockingJob(ReplaceConcurrencyPolicy, make(chan struct{}))
+	s.dispatch(job)
+	s.dispatch(job)
+
+	// ALLOW：并行执行
+	job = blockingJob(AllowConcurrencyPolicy, release)
+	s.dispatch(job)
+	s.dispatch(job)
+	if n := s.executingCount(job.Id); n != 2 {
+		t.Errorf("ALLOW：期望执行中2个，实际%d个", n)
+	}
+
+	close(release)
+	deadline := time.After(5 * time.Second)
+	for len(s.executions) > 1 { // 仅剩REPLACE的第二次执行
+		select {
+		case id := <-s.finished:
+			if e, ok := s.executions[id]; ok {
+				delete(s.executions, id)
+				s.launchQueued(e.jobId)
+			}
+		case <-deadline:
+			t.Fatalf("等待执行结束超时，剩余%d个", len(s.executions))
+		}
+	}
+	time.Sleep(100 * time.Millisecond) // 等待SKIPPED记录写入
+
+	if c := countStates(t, s.store, "job-"+SkipConcurrencyPolicy); c[SuccessJobExecuteState] != 1 ||
+		c[SkippedJobExecuteState] != 1 {
+		t.Errorf("SKIP：执行记录不符合预期：%v", c)
+	}
+	if c := countStates(t, s.store, "job-"+QueueConcurrencyPolicy); c[SuccessJobExecuteState] != 2 ||
+		c[SkippedJobExecuteState] != 1 {
+		t.Errorf("QUEUE：执行记录不符合预期：%v", c)
+	}
+	if c := countStates(t, s.store, "job-"+ReplaceConcurrencyPolicy); c[CancelledJobExecuteState] != 1 {
+		t.Errorf("REPLACE：执行记录不符合预期：%v", c)
+	}
+}
+
+func TestProcessTriggerJobCMD(t *testing.T) {
+	s := newScheduler(NewMemoryStore(), RealClock)
+	next := time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local)
+	args := make(chan string, 1)
+	job := &Job{JobCore: JobCore{Id: "job-trigger", RunnerArgs: "golang"}, Next: next}
+	job.Runner = func(ctx context.Context, a string) error {
+		args <- a
+		return nil
+	}
+	s.jobs = []*Job{job}
+
+	if _, err := s.processTriggerJobCMD(job.Id, &Trigger{}); err != ErrSchedulerNotRunning {
+		t.Errorf("调度器未运行时应返回ErrSchedulerNotRunning，实际：%v", err)
+	}
+	s.running = true
+	if _, err := s.processTriggerJobCMD("not-exist", &Trigger{}); err != ErrJobNotFound {
+		t.Errorf("作业不存在时应返回ErrJobNotFound，实际：%v", err)
+	}
+
+	override := "rust"
+	resultId, err := s.processTriggerJobCMD(job.Id, &Trigger{By: "admin", RunnerArgs: &override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := <-args; a != override {
+		t.Errorf("爬虫参数应被覆盖为%s，实际%s", override, a)
+	}
+	<-s.finished
+	if !job.Next.Equal(next) || job.RunnerArgs != "golang" {
+		t.Errorf("手动触发不应影响作业本身：%+v", job)
+	}
+
+	results, _, err := s.store.QueryResults(&ResultQuery{OriginId: resultId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Manual || results[0].TriggeredBy != "admin" ||
+		results[0].JobRunnerArgs != override || results[0].ExecuteState != SuccessJobExecuteState {
+		t.Errorf("手动触发的执行记录不符合预期：%+v", results)
+	}
+}
@@ -2,31 +2,69 @@ package scheduler
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
+	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 type Cron struct {
-	Second, Minute, Hour, Dom, Month, Dow uint64 // 位模式，用0-63位表示整数0-63
+	Second, Minute, Hour, Dom, Month, Dow uint64         // 位模式，用0-63位表示整数0-63
+	Location                              *time.Location // 时区，由CRON_TZ=或TZ=前缀指定，nil表示使用给定时间的时区
+
+	// 日字段的扩展语法
+	DomLast        bool   // L：每月最后一天
+	DomLastWeekday bool   // LW：每月最后一个工作日（周一至周五）
+	DomWeekday     uint64 // nW：离每月n日最近的工作日，不跨月，位模式
+	// 星期字段的扩展语法
+	DowLast uint64 // nL：每月最后一个星期n，位模式
+	DowNth  uint64 // n#k：每月第k个星期n，位模式，用第n*8+k位表示
+
+	Year []int // 允许的年份，升序，nil表示不限制
 }
 
+const starBit uint64 = 1 << 63 // 位模式，用最高位表示*
+
+// Cron表达式的字段格式，用于明确指定各字段的含义
+type CronFormat int
+
 const (
-	starBit              uint64 = 1 << 63 // 位模式，用最高位表示*
-	cronScheduleFiledNum        = 6       // 秒 分 时 日 月 星期
+	AutoCronFormat     CronFormat = iota // 按字段数识别：5个字段为StandardCronFormat，6个为SecondsCronFormat，7个为QuartzCronFormat
+	StandardCronFormat                   // 标准crontab格式，5个字段：分 时 日 月 星期，秒固定为0
+	SecondsCronFormat                    // 6个字段：秒 分 时 日 月 星期
+	QuartzCronFormat                     // Quartz格式，7个字段：秒 分 时 日 月 星期 年
 )
 
+// 解析Cron表达式的选项
+type CronOptions struct {
+	Format CronFormat // 字段格式，默认按字段数识别
+	Seed   string     // H和R的种子，通常为作业ID，使同一作业的取值稳定而不同作业的取值分散，为空时R每次随机取值
+}
+
+// 各格式的字段数
+var cronFormatFieldNum = map[CronFormat]int{
+	StandardCronFormat: 5,
+	SecondsCronFormat:  6,
+	QuartzCronFormat:   7,
+}
+
 type bounds struct {
 	min, max uint
 	names    map[string]uint
+	wrap     bool // 是否允许跨越上限的范围，例如22-2、fri-mon
 }
 
 var (
-	seconds     = bounds{0, 59, nil}
-	minutes     = bounds{0, 59, nil}
-	hours       = bounds{0, 23, nil}
-	daysOfMonth = bounds{1, 31, nil}
+	seconds     = bounds{0, 59, nil, false}
+	minutes     = bounds{0, 59, nil, false}
+	hours       = bounds{0, 23, nil, true}
+	years       = bounds{1970, 2099, nil, false}
+	daysOfMonth = bounds{1, 31, nil, false}
 	months      = bounds{1, 12, map[string]uint{
 		"jan": 1,
 		"feb": 2,
@@ -40,8 +78,9 @@ var (
 		"oct": 10,
 		"nov": 11,
 		"dec": 12,
-	}}
+	}, true}
 	daysOfWeek = bounds{0, 6, map[string]uint{
+		"7":   0, // 星期日也可以写作7
 		"sun": 0,
 		"mon": 1,
 		"tue": 2,
@@ -49,7 +88,7 @@ var (
 		"thu": 4,
 		"fri": 5,
 		"sat": 6,
-	}}
+	}, true}
 )
 
 /*
@@ -57,103 +96,240 @@ Cron表达式格式：
 *    *    *    *    *    *
 -    -    -    -    -    -
 |    |    |    |    |    |
-|    |    |    |    |    + day of week (0 - 6) (Sunday=0)
+|    |    |    |    |    + day of week (0 - 6) (Sunday=0)，支持nL（最后一个星期n）、n#k（第k个星期n）
 |    |    |    |    +----- month (1 - 12)
-|    |    |    +---------- day of month (1 - 31)
+|    |    |    +---------- day of month (1 - 31)，支持L（最后一天）、LW（最后一个工作日）、nW（离n日最近的工作日）
 |    |    +--------------- hour (0 - 23)
 |    +-------------------- min (0 - 59)
 +------------------------- second (0 - 59)
+
+省略秒字段时为标准crontab的5个字段，秒固定为0；末尾可增加年字段（1970 - 2099）成为Quartz的7个字段
+也可以使用预定义的@yearly、@monthly、@weekly、@daily、@hourly等代替全部字段
+可以用CRON_TZ=或TZ=前缀指定时区，例如：CRON_TZ=Asia/Shanghai 0 0 8 * * *、CRON_TZ=Asia/Shanghai @daily
+
+各字段均可用H（按种子哈希取值）或R（随机取值）分散执行时刻，例如：H H * * * *、H(0-29) H/15 * * * *
+H只由种子决定；R由种子和调度规则原文决定，同一作业重启或重载后取值不变，修改调度规则后重新随机取值，没有种子时每次解析都随机取值
+年字段的H和R必须指定范围，例如H(2030-2035)，以免取到过去的年份而不再执行
 */
 func NewCron(cron string) (*Cron, error) {
+	return NewCronWithOptions(cron, CronOptions{})
+}
+
+// 按指定的字段格式解析Cron表达式，预定义的调度规则不受格式限制
+func NewCronWithFormat(cron string, format CronFormat) (*Cron, error) {
+	return NewCronWithOptions(cron, CronOptions{Format: format})
+}
+
+// 按选项解析Cron表达式
+func NewCronWithOptions(cron string, opts CronOptions) (*Cron, error) {
+	format := opts.Format
 	if len(cron) == 0 {
-		return nil, fmt.Errorf("时间表达式为空")
+		return nil, &CronParseError{Index: -1, Reason: "时间表达式为空"}
+	}
+	exprError := func(position int, value, reason string) error {
+		return &CronParseError{Expr: cron, Index: -1, Position: position, Value: value, Reason: reason}
 	}
 
-	fields := strings.Fields(cron)
+	fields, offsets := splitFields(cron)
 
-	if count := len(fields); count != cronScheduleFiledNum {
-		return nil, fmt.Errorf("期望%d个字段，然而传入了%d个字段，表达式：\"%s\"", cronScheduleFiledNum, count, cron)
+	var loc *time.Location
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		var err error
+		name := fields[0][strings.Index(fields[0], "=")+1:]
+		if loc, err = time.LoadLocation(name); err != nil || name == "" {
+			return nil, exprError(offsets[0], fields[0], fmt.Sprintf("时区非法，时区：\"%s\"", name))
+		}
+		fields, offsets = fields[1:], offsets[1:]
 	}
 
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		spec, ok := descriptors[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, exprError(offsets[0], fields[0], "未知的预定义调度规则")
+		}
+		offset := offsets[0]
+		fields, offsets = strings.Fields(spec), nil
+		for range fields {
+			offsets = append(offsets, offset)
+		}
+		format = SecondsCronFormat
+	}
+
+	count := len(fields)
+	if format == AutoCronFormat {
+		for f, num := range cronFormatFieldNum {
+			if num == count {
+				format = f
+			}
+		}
+		if format == AutoCronFormat {
+			return nil, exprError(0, "", fmt.Sprintf("期望5、6或7个字段，然而传入了%d个字段", count))
+		}
+	}
+	num, ok := cronFormatFieldNum[format]
+	if !ok {
+		return nil, exprError(0, "", fmt.Sprintf("未知的Cron表达式格式：%d", format))
+	}
+	if count != num {
+		return nil, exprError(0, "", fmt.Sprintf("期望%d个字段，然而传入了%d个字段", num, count))
+	}
+	index := 0 // fields[i]在表达式中的序号为i-index
+	if format == StandardCronFormat {
+		fields, offsets, index = append([]string{"0"}, fields...), append([]int{offsets[0]}, offsets...), 1
+	}
+
+	// 补充出错的字段和位置
 	var err error
-	field := func(field string, r bounds) uint64 {
+	fieldError := func(i int) error {
+		e, ok := err.(*CronParseError)
+		if !ok {
+			e = &CronParseError{Value: fields[i], Reason: err.Error()}
+		}
+		e.Expr, e.Field, e.Index = cron, cronFields[i].name, i-index
+		e.Position += offsets[i]
+		return e
+	}
+
+	for i := range fields {
+		hash, random := hashSeed(opts.Seed, i), randomSeed(opts.Seed, cron, i)
+		if fields[i], err = expandHashed(fields[i], hashedBounds[i], hash, random); err != nil {
+			return nil, fieldError(i)
+		}
+	}
+
+	c := &Cron{Location: loc}
+	field := func(i int, r bounds, special func(expr string) (bool, error)) uint64 {
 		if err != nil {
 			return 0
 		}
 		var bits uint64
-		bits, err = getField(field, r)
+		if bits, err = getField(fields[i], r, special); err != nil {
+			err = fieldError(i)
+		}
 		return bits
 	}
 
-	var (
-		second     = field(fields[0], seconds)
-		minute     = field(fields[1], minutes)
-		hour       = field(fields[2], hours)
-		dayOfMonth = field(fields[3], daysOfMonth)
-		month      = field(fields[4], months)
-		dayOfWeek  = field(fields[5], daysOfWeek)
-	)
+	c.Second = field(0, seconds, nil)
+	c.Minute = field(1, minutes, nil)
+	c.Hour = field(2, hours, nil)
+	c.Dom = field(3, daysOfMonth, c.parseDomSpecial)
+	c.Month = field(4, months, nil)
+	c.Dow = field(5, daysOfWeek, c.parseDowSpecial)
+	if err == nil && format == QuartzCronFormat {
+		if c.Year, err = getYears(fields[6]); err != nil {
+			err = fieldError(6)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	return c, nil
+}
+
+// 依次为秒 分 时 日 月 星期 年，name用于CronParseError.Field
+var cronFields = []struct {
+	name, zh string
+}{
+	{"second", "秒"},
+	{"minute", "分钟"},
+	{"hour", "小时"},
+	{"day_of_month", "日"},
+	{"month", "月"},
+	{"day_of_week", "星期"},
+	{"year", "年"},
+}
+
+// 按空白分割表达式，同时返回各字段的起始位置（字节偏移）
+func splitFields(s string) ([]string, []int) {
+	var (
+		fields  []string
+		offsets []int
+	)
+	start := -1
+	for i, r := range s + " " {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				fields = append(fields, s[start:i])
+				offsets = append(offsets, start)
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return fields, offsets
+}
 
-	return &Cron{
-		Second: second,
-		Minute: minute,
-		Hour:   hour,
-		Dom:    dayOfMonth,
-		Month:  month,
-		Dow:    dayOfWeek,
-	}, nil
+// 字段中第i部分（逗号分隔）的解析错误，位置为该部分在字段中的偏移
+func itemError(items []string, i int, err error) error {
+	offset := 0
+	for _, item := range items[:i] {
+		offset += len(item) + 1
+	}
+	return &CronParseError{Position: offset, Value: items[i], Reason: err.Error()}
 }
 
-// 返回5年内晚于给定时间的下一个执行时刻，如果没有，则返回时间0
+// 返回晚于给定时间的下一个执行时刻，如果没有，则返回时间0
+// 不限制年份时只在5年内查找，否则在允许的年份内查找
+// 按Location（为nil时取给定时间的时区）的墙上时间计算，返回的时刻与给定时间同时区
+// 夏令时开始时被跳过的时刻不执行；夏令时结束时重复的时刻，小时字段为*时按实际时间执行两次，否则只在第一次出现时执行
 func (s *Cron) Next(a time.Time) time.Time {
+	loc := a.Location()
+	if s.Location != nil {
+		a = a.In(s.Location)
+	}
 	start := a.Add(time.Second).Truncate(time.Second) // 开始时间，向上取整到秒
 	end := a.AddDate(5, 0, 0)                         // 最大结束时间，5年后
+	if len(s.Year) > 0 {
+		end = startOfDay(s.Year[len(s.Year)-1]+1, time.January, 1, a.Location()) // 允许的最后一年结束
+	}
 
 	next := start
+WRAP:
 	for {
 		// 时间超过最大区间限制
 		if next.After(end) {
 			return time.Time{} // 返回时间0值(t.IsZero()->true)
 		}
 
+		// 年份不匹配，直接跳到下一个允许的年份
+		if year := s.nextYear(next.Year()); year == 0 {
+			return time.Time{}
+		} else if year != next.Year() {
+			next = startOfDay(year, time.January, 1, next.Location()) // 重置年以下的低位
+		}
+
 		// 月份不匹配
 		for 1<<uint(next.Month())&s.Month == 0 {
-			next = next.AddDate(0, 1, 0) // 累加月
-			next = time.Date(next.Year(), next.Month(), 1,
-				0, 0, 0, 0, next.Location()) //  重置月以下的低位
+			next = startOfDay(next.Year(), next.Month()+1, 1, next.Location()) // 累加月，重置月以下的低位
 			if next.Month() == time.January {
-				continue // 进位，重新从高位往低位验证
+				continue WRAP // 进位，重新从高位往低位验证
 			}
 		}
 
 		// 日不匹配
 		for !dayMatches(s, next) {
-			next = next.AddDate(0, 0, 1) // 累加天
-			next = time.Date(next.Year(), next.Month(), next.Day(),
-				0, 0, 0, 0, next.Location()) //  重置天以下的低位
+			next = startOfDay(next.Year(), next.Month(), next.Day()+1, next.Location()) // 累加天，重置天以下的低位
 			if next.Day() == 1 {
-				continue // 进位，重新从高位往低位验证
+				continue WRAP // 进位，重新从高位往低位验证
 			}
 		}
 
 		// 时钟不匹配
 		for 1<<uint(next.Hour())&s.Hour == 0 {
-			next = next.Add(1 * time.Hour)  // 累加小时
-			next = next.Truncate(time.Hour) // 重置小时以下的低位
-			if next.Hour() == 0 {
-				continue // 进位，重新从高位往低位验证
+			day := next.Day()
+			next = next.Add(time.Hour - time.Duration(next.Minute())*time.Minute -
+				time.Duration(next.Second())*time.Second) // 累加小时，重置小时以下的低位
+			if next.Day() != day {
+				continue WRAP // 进位，重新从高位往低位验证
 			}
 		}
 
 		// 分钟不匹配
 		for 1<<uint(next.Minute())&s.Minute == 0 {
-			next = next.Add(1 * time.Minute)  // 累加分钟
-			next = next.Truncate(time.Minute) // 重置分钟以下的低位
+			next = next.Add(time.Minute - time.Duration(next.Second())*time.Second) // 累加分钟，重置分钟以下的低位
 			if next.Minute() == 0 {
-				continue // 进位，重新从高位往低位验证
+				continue WRAP // 进位，重新从高位往低位验证
 			}
 		}
 
@@ -161,24 +337,134 @@ func (s *Cron) Next(a time.Time) time.Time {
 		for 1<<uint(next.Second())&s.Second == 0 {
 			next = next.Add(1 * time.Second) // 累加秒钟
 			if next.Second() == 0 {
-				continue // 进位，重新从高位往低位验证
+				continue WRAP // 进位，重新从高位往低位验证
 			}
 		}
 
+		// 夏令时结束时重复的时刻，非每小时执行的作业只在第一次出现时执行
+		if s.Hour&starBit == 0 && isRepeatedWallTime(next) {
+			next = next.Add(1 * time.Second)
+			continue
+		}
+
 		// 时间超过最大区间限制
 		if next.After(end) {
 			return time.Time{} // 返回时间0值(t.IsZero()->true)
 		}
-		break
+		return next.In(loc)
+	}
+}
+
+// 返回早于给定时间的上一个执行时刻，如果没有，则返回时间0
+// 查找区间、时区和夏令时的处理与Next一致：不限制年份时只在5年内查找，否则在允许的年份内查找
+func (s *Cron) Prev(a time.Time) time.Time {
+	loc := a.Location()
+	if s.Location != nil {
+		a = a.In(s.Location)
+	}
+	start := a.Add(-time.Nanosecond).Truncate(time.Second) // 开始时间，早于给定时间并向下取整到秒
+	end := a.AddDate(-5, 0, 0)                             // 最小结束时间，5年前
+	if len(s.Year) > 0 {
+		end = startOfDay(s.Year[0], time.January, 1, a.Location()) // 允许的第一年开始
+	}
+
+	prev := start
+WRAP:
+	for {
+		// 时间超过最大区间限制
+		if prev.Before(end) {
+			return time.Time{} // 返回时间0值(t.IsZero()->true)
+		}
+
+		// 年份不匹配，直接退到上一个允许的年份的最后一秒
+		if year := s.prevYear(prev.Year()); year == 0 {
+			return time.Time{}
+		} else if year != prev.Year() {
+			prev = startOfDay(year+1, time.January, 1, prev.Location()).Add(-time.Second)
+		}
+
+		// 月份不匹配
+		for 1<<uint(prev.Month())&s.Month == 0 {
+			prev = startOfDay(prev.Year(), prev.Month(), 1, prev.Location()).Add(-time.Second) // 退到上个月的最后一秒
+			if prev.Month() == time.December {
+				continue WRAP // 借位，重新从高位往低位验证
+			}
+		}
+
+		// 日不匹配
+		for !dayMatches(s, prev) {
+			month := prev.Month()
+			prev = startOfDay(prev.Year(), prev.Month(), prev.Day(), prev.Location()).Add(-time.Second) // 退到前一天的最后一秒
+			if prev.Month() != month {
+				continue WRAP // 借位，重新从高位往低位验证
+			}
+		}
+
+		// 时钟不匹配
+		for 1<<uint(prev.Hour())&s.Hour == 0 {
+			day := prev.Day()
+			prev = prev.Add(-time.Duration(prev.Minute())*time.Minute -
+				time.Duration(prev.Second()+1)*time.Second) // 退到上一小时的最后一秒
+			if prev.Day() != day {
+				continue WRAP // 借位，重新从高位往低位验证
+			}
+		}
+
+		// 分钟不匹配
+		for 1<<uint(prev.Minute())&s.Minute == 0 {
+			prev = prev.Add(-time.Duration(prev.Second()+1) * time.Second) // 退到上一分钟的最后一秒
+			if prev.Minute() == 59 {
+				continue WRAP // 借位，重新从高位往低位验证
+			}
+		}
+
+		// 秒钟不匹配
+		for 1<<uint(prev.Second())&s.Second == 0 {
+			prev = prev.Add(-1 * time.Second) // 递减秒钟
+			if prev.Second() == 59 {
+				continue WRAP // 借位，重新从高位往低位验证
+			}
+		}
+
+		// 夏令时结束时重复的时刻，非每小时执行的作业只在第一次出现时执行
+		if s.Hour&starBit == 0 && isRepeatedWallTime(prev) {
+			prev = prev.Add(-1 * time.Second)
+			continue
+		}
+
+		// 时间超过最大区间限制
+		if prev.Before(end) {
+			return time.Time{} // 返回时间0值(t.IsZero()->true)
+		}
+		return prev.In(loc)
 	}
+}
 
-	return next
+// 给定日期的第一个时刻，通常为0点，0点因夏令时不存在时顺延至当天第一个存在的时刻
+func startOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	noon := time.Date(year, month, day, 12, 0, 0, 0, loc) // 规范化日期，正午总是存在
+	t := time.Date(noon.Year(), noon.Month(), noon.Day(), 0, 0, 0, 0, loc)
+	for t.Day() != noon.Day() { // 0点不存在时可能被规范化到前一天
+		t = t.Add(time.Hour)
+	}
+	return t
+}
+
+// t的墙上时间是否已在更早的时刻出现过，即夏令时结束时重复时间段中的第二次出现
+func isRepeatedWallTime(t time.Time) bool {
+	_, offset := t.Zone()
+	_, before := t.Add(-24 * time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+	_, earlier := t.Add(-time.Duration(before-offset) * time.Second).Zone()
+	return earlier == before
 }
 
 func dayMatches(s *Cron, t time.Time) bool {
 	var (
-		domMatch = 1<<uint(t.Day())&s.Dom > 0
-		dowMatch = 1<<uint(t.Weekday())&s.Dow > 0
+		domMatch = 1<<uint(t.Day())&s.Dom > 0 || s.domSpecialMatches(t)
+		dowMatch = 1<<uint(t.Weekday())&s.Dow > 0 || s.dowSpecialMatches(t)
 	)
 	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
 		return domMatch && dowMatch
@@ -186,13 +472,23 @@ func dayMatches(s *Cron, t time.Time) bool {
 	return domMatch || dowMatch
 }
 
-func getField(field string, r bounds) (uint64, error) {
+// 解析字段，special不为nil时先尝试解析扩展语法，解析成功的部分不计入返回的位模式
+func getField(field string, r bounds, special func(expr string) (bool, error)) (uint64, error) {
 	var bits uint64
 	ranges := strings.Split(field, ",")
-	for _, expr := range ranges {
+	for i, expr := range ranges {
+		if special != nil {
+			ok, err := special(expr)
+			if err != nil {
+				return bits, itemError(ranges, i, err)
+			}
+			if ok {
+				continue
+			}
+		}
 		bit, err := getRange(expr, r)
 		if err != nil {
-			return bits, err
+			return bits, itemError(ranges, i, err)
 		}
 		bits |= bit
 	}
@@ -200,23 +496,46 @@ func getField(field string, r bounds) (uint64, error) {
 }
 
 func getRange(expr string, r bounds) (uint64, error) {
+	start, end, step, star, err := parseRange(expr, r)
+	if err != nil {
+		return 0, err
+	}
+	var extra uint64
+	if star {
+		extra = starBit
+	}
+	if start > end { // 跨越上限的范围
+		return getWrapBits(start, end, step, r) | extra, nil
+	}
+	return getBits(start, end, step) | extra, nil
+}
+
+// 跨越上限的范围的位模式，例如22-2表示22、23、0、1、2，步长跨越上限连续计算
+func getWrapBits(start, end, step uint, r bounds) uint64 {
+	var bits uint64
+	span := r.max - r.min + 1
+	for k := uint(0); k <= end+span-start; k += step {
+		bits |= 1 << (r.min + (start-r.min+k)%span)
+	}
+	return bits
+}
+
+// 解析单个范围表达式：*、?、n、a-b、n/step、a-b/step，star表示是否为*或?
+func parseRange(expr string, r bounds) (start, end, step uint, star bool, err error) {
 	var (
-		start, end, step uint
-		rangeAndStep     = strings.Split(expr, "/")
-		lowAndHigh       = strings.Split(rangeAndStep[0], "-")
-		singleDigit      = len(lowAndHigh) == 1
-		err              error
+		rangeAndStep = strings.Split(expr, "/")
+		lowAndHigh   = strings.Split(rangeAndStep[0], "-")
+		singleDigit  = len(lowAndHigh) == 1
 	)
 
-	var extra uint64
 	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
 		start = r.min
 		end = r.max
-		extra = starBit
+		star = true
 	} else {
 		start, err = parseIntOrName(lowAndHigh[0], r.names)
 		if err != nil {
-			return 0, err
+			return
 		}
 		switch len(lowAndHigh) {
 		case 1:
@@ -224,10 +543,15 @@ func getRange(expr string, r bounds) (uint64, error) {
 		case 2:
 			end, err = parseIntOrName(lowAndHigh[1], r.names)
 			if err != nil {
-				return 0, err
+				return
+			}
+			// 0-7、sun-7等首尾为同一取值的不同写法，表示全部取值
+			if r.wrap && start == end && lowAndHigh[0] != lowAndHigh[1] {
+				start, end = r.min, r.max
 			}
 		default:
-			return 0, fmt.Errorf("只允许一个‘_’符号，表达式：\"%s\"", expr)
+			err = fmt.Errorf("只允许一个‘-’符号")
+			return
 		}
 	}
 
@@ -237,29 +561,307 @@ func getRange(expr string, r bounds) (uint64, error) {
 	case 2:
 		step, err = mustParseInt(rangeAndStep[1])
 		if err != nil {
-			return 0, err
+			return
 		}
 		// 特殊处理 N/step -> N-max/step
 		if singleDigit {
 			end = r.max
 		}
 	default:
-		return 0, fmt.Errorf("只允许一个‘/’符号，表达式：\"%s\"", expr)
+		err = fmt.Errorf("只允许一个‘/’符号")
+		return
 	}
 
-	if start < r.min {
-		return 0, fmt.Errorf("该字段起始取值%d低于下限%d，表达式：\"%s\"", start, r.min, expr)
+	switch {
+	case start < r.min:
+		err = fmt.Errorf("该字段起始取值%d低于下限%d", start, r.min)
+	case end > r.max:
+		err = fmt.Errorf("该字段结束取值%d高于上限%d", end, r.max)
+	case start > end && !r.wrap:
+		err = fmt.Errorf("该字段起始取值%d高于结束取值%d", start, end)
+	case step == 0:
+		err = fmt.Errorf("步长必须为正整数")
+	case len(rangeAndStep) == 2 && isSundaySeven(lowAndHigh[0], r):
+		// 按0计算时7/2表示周日、二、四、六，与classic cron中从7起算（仅周日）的含义不同
+		err = fmt.Errorf("星期日写作7时不能作为带步长的范围的起始取值，请使用0或sun")
 	}
-	if end > r.max {
-		return 0, fmt.Errorf("该字段结束取值%d高于上限%d，表达式：\"%s\"", end, r.max, expr)
+	return
+}
+
+// 是否为星期字段中星期日的另一种写法7
+func isSundaySeven(expr string, r bounds) bool {
+	_, ok := r.names[expr]
+	return ok && expr == "7"
+}
+
+// 解析年字段，超出位模式的表示范围，返回升序的年份列表，*或?返回nil表示不限制
+func getYears(field string) ([]int, error) {
+	allowed := make(map[int]bool)
+	exprs := strings.Split(field, ",")
+	for i, expr := range exprs {
+		start, end, step, star, err := parseRange(expr, years)
+		if err != nil {
+			return nil, itemError(exprs, i, err)
+		}
+		if star && step == 1 {
+			return nil, nil
+		}
+		for y := start; y <= end; y += step {
+			allowed[int(y)] = true
+		}
 	}
-	if start > end {
-		return 0, fmt.Errorf("该字段起始取值%d高于结束取值%d，表达式：\"%s\"", start, end, expr)
+	list := make([]int, 0, len(allowed))
+	for y := range allowed {
+		list = append(list, y)
 	}
-	if step == 0 {
-		return 0, fmt.Errorf("步长必须为正整数，表达式：\"%s\"", expr)
+	sort.Ints(list)
+	return list, nil
+}
+
+// 不早于year的第一个允许的年份，没有时返回0
+func (s *Cron) nextYear(year int) int {
+	if len(s.Year) == 0 {
+		return year
 	}
-	return getBits(start, end, step) | extra, nil
+	i := sort.SearchInts(s.Year, year)
+	if i == len(s.Year) {
+		return 0
+	}
+	return s.Year[i]
+}
+
+// 不晚于year的最后一个允许的年份，没有时返回0
+func (s *Cron) prevYear(year int) int {
+	if len(s.Year) == 0 {
+		return year
+	}
+	i := sort.SearchInts(s.Year, year+1)
+	if i == 0 {
+		return 0
+	}
+	return s.Year[i-1]
+}
+
+// H和R的取值范围，max为未指定范围时的上限
+type hashedBound struct {
+	r         bounds
+	max       uint
+	rangeOnly bool // 必须指定范围，年份在全部取值内哈希或随机可能取到过去的年份，作业将不再执行
+}
+
+// 依次为秒 分 时 日 月 星期 年，日默认只取1-28以保证每月都执行
+var hashedBounds = []hashedBound{
+	{seconds, seconds.max, false},
+	{minutes, minutes.max, false},
+	{hours, hours.max, false},
+	{daysOfMonth, 28, false},
+	{months, months.max, false},
+	{daysOfWeek, daysOfWeek.max, false},
+	{years, years.max, true},
+}
+
+var (
+	hashedMu   sync.Mutex
+	hashedRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// 种子和字段序号的哈希值，使同一种子的各字段取值不同
+func hashSeed(seed string, field int) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(seed + "#" + strconv.Itoa(field)))
+	return h.Sum32()
+}
+
+// R的取值，有种子时由种子和调度规则原文决定，同一作业的同一规则每次构建取值相同，修改规则后重新取值；
+// 没有种子时（例如预览调度规则）每次解析随机取值
+func randomSeed(seed, cron string, field int) uint32 {
+	if seed == "" {
+		hashedMu.Lock()
+		defer hashedMu.Unlock()
+		return hashedRand.Uint32()
+	}
+	return hashSeed(seed+"#"+cron, field)
+}
+
+// 将字段中的H、H(a-b)、H/n、H(a-b)/n及对应的R语法替换为具体取值，例如：H/15 -> 7-59/15
+func expandHashed(field string, hb hashedBound, hash, random uint32) (string, error) {
+	exprs := strings.Split(field, ",")
+	for i, expr := range exprs {
+		if !strings.HasPrefix(expr, "H") && !strings.HasPrefix(expr, "R") {
+			continue
+		}
+		expanded, err := expandHashedExpr(expr, hb, hash, random)
+		if err != nil {
+			return "", itemError(exprs, i, err)
+		}
+		exprs[i] = expanded
+	}
+	return strings.Join(exprs, ","), nil
+}
+
+func expandHashedExpr(expr string, hb hashedBound, hash, random uint32) (string, error) {
+	n := hash
+	if expr[0] == 'R' {
+		n = random
+	}
+
+	low, high, rest := hb.r.min, hb.max, expr[1:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("缺少‘)’符号")
+		}
+		start, stop, step, _, err := parseRange(rest[1:end], hb.r)
+		if err != nil {
+			return "", err
+		}
+		if step != 1 || !strings.Contains(rest[1:end], "-") || start > stop {
+			return "", fmt.Errorf("括号内只允许不跨越上限的范围a-b")
+		}
+		low, high, rest = start, stop, rest[end+1:]
+	} else if hb.rangeOnly {
+		return "", fmt.Errorf("该字段的%c必须指定范围，例如%c(2030-2035)", expr[0], expr[0])
+	}
+
+	switch {
+	case rest == "":
+		return strconv.Itoa(int(low + uint(n)%(high-low+1))), nil
+	case strings.HasPrefix(rest, "/"):
+		step, err := mustParseInt(rest[1:])
+		if err != nil {
+			return "", err
+		}
+		if step == 0 {
+			return "", fmt.Errorf("步长必须为正整数")
+		}
+		span := high - low + 1
+		if step < span {
+			span = step
+		}
+		return fmt.Sprintf("%d-%d/%d", low+uint(n)%span, high, step), nil
+	default:
+		return "", fmt.Errorf("%c后只允许(a-b)或/n", expr[0])
+	}
+}
+
+// 解析日字段的扩展语法：L、LW、nW，不是扩展语法时返回false
+func (s *Cron) parseDomSpecial(expr string) (bool, error) {
+	upper := strings.ToUpper(expr)
+	switch {
+	case upper == "L":
+		s.DomLast = true
+	case upper == "LW":
+		s.DomLastWeekday = true
+	case strings.HasSuffix(upper, "W"):
+		day, err := mustParseInt(expr[:len(expr)-1])
+		if err != nil {
+			return false, err
+		}
+		if day < daysOfMonth.min || day > daysOfMonth.max {
+			return false, fmt.Errorf("W前的日取值%d超出范围%d-%d", day, daysOfMonth.min, daysOfMonth.max)
+		}
+		s.DomWeekday |= 1 << day
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// 解析星期字段的扩展语法：nL、n#k，不是扩展语法时返回false
+func (s *Cron) parseDowSpecial(expr string) (bool, error) {
+	upper := strings.ToUpper(expr)
+	switch {
+	case strings.HasSuffix(upper, "L") && len(expr) > 1:
+		dow, err := parseDow(expr[:len(expr)-1])
+		if err != nil {
+			return false, err
+		}
+		s.DowLast |= 1 << dow
+	case strings.Contains(expr, "#"):
+		parts := strings.Split(expr, "#")
+		if len(parts) != 2 {
+			return false, fmt.Errorf("只允许一个‘#’符号")
+		}
+		dow, err := parseDow(parts[0])
+		if err != nil {
+			return false, err
+		}
+		nth, err := mustParseInt(parts[1])
+		if err != nil {
+			return false, err
+		}
+		if nth < 1 || nth > 5 {
+			return false, fmt.Errorf("#后的序号%d超出范围1-5", nth)
+		}
+		s.DowNth |= 1 << (dow*8 + nth)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func parseDow(field string) (uint, error) {
+	dow, err := parseIntOrName(field, daysOfWeek.names)
+	if err != nil {
+		return 0, err
+	}
+	if dow > daysOfWeek.max {
+		return 0, fmt.Errorf("星期取值%d超出范围%d-%d", dow, daysOfWeek.min, daysOfWeek.max)
+	}
+	return dow, nil
+}
+
+// 当月的天数
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 12, 0, 0, 0, t.Location()).Day()
+}
+
+// 离当月day日最近的工作日，不跨月，day超过当月天数时返回0
+func nearestWeekday(t time.Time, day int) int {
+	last := daysIn(t)
+	if day > last {
+		return 0
+	}
+	switch time.Date(t.Year(), t.Month(), day, 12, 0, 0, 0, t.Location()).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+func (s *Cron) domSpecialMatches(t time.Time) bool {
+	day := t.Day()
+	if s.DomLast && day == daysIn(t) {
+		return true
+	}
+	if s.DomLastWeekday && day == nearestWeekday(t, daysIn(t)) {
+		return true
+	}
+	if s.DomWeekday != 0 {
+		for n := daysOfMonth.min; n <= daysOfMonth.max; n++ {
+			if 1<<n&s.DomWeekday > 0 && nearestWeekday(t, int(n)) == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Cron) dowSpecialMatches(t time.Time) bool {
+	dow := uint(t.Weekday())
+	if 1<<dow&s.DowLast > 0 && t.Day()+7 > daysIn(t) {
+		return true
+	}
+	nth := uint(t.Day()-1)/7 + 1
+	return 1<<(dow*8+nth)&s.DowNth > 0
 }
 
 func parseIntOrName(expr string, names map[string]uint) (uint, error) {
@@ -274,10 +876,10 @@ func parseIntOrName(expr string, names map[string]uint) (uint, error) {
 func mustParseInt(expr string) (uint, error) {
 	num, err := strconv.Atoi(expr)
 	if err != nil {
-		return 0, fmt.Errorf("解析整数失败，表达式：\"%s\"，错误：%s", expr, err)
+		return 0, fmt.Errorf("解析整数\"%s\"失败：%s", expr, err)
 	}
 	if num < 0 {
-		return 0, fmt.Errorf("不允许负数，表达式：\"%s\"", expr)
+		return 0, fmt.Errorf("不允许负数%s", expr)
 	}
 	return uint(num), nil
 }
@@ -25,3 +25,350 @@ func Test_BuildCron(t *testing.T) {
 		}
 	}
 }
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// 依次计算from之后的执行时刻，与want逐个比较
+func checkNext(t *testing.T, expr string, from time.Time, want ...time.Time) {
+	t.Helper()
+	cron, err := NewCron(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := from
+	for i, w := range want {
+		next = cron.Next(next)
+		if !next.Equal(w) {
+			t.Fatalf("%s：第%d个执行时刻期望%v，实际%v", expr, i+1, w, next)
+		}
+	}
+}
+
+func TestCronNextCarry(t *testing.T) {
+	utc := func(d, h, m, s int) time.Time { return time.Date(2019, 6, d, h, m, s, 0, time.UTC) }
+	checkNext(t, "0 30 1 * * *", utc(1, 1, 30, 0), utc(2, 1, 30, 0), utc(3, 1, 30, 0))
+	checkNext(t, "0 0 0 1 * *", utc(1, 0, 0, 0), time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC))
+	checkNext(t, "30 * * * * *", utc(1, 23, 59, 30), utc(2, 0, 0, 30))
+}
+
+func TestCronTimeZone(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	from := time.Date(2019, 5, 31, 23, 0, 0, 0, time.UTC)
+	for _, expr := range []string{"CRON_TZ=Asia/Shanghai 0 0 8 * * *", "TZ=Asia/Shanghai 0 0 8 * * *"} {
+		checkNext(t, expr, from, time.Date(2019, 6, 1, 8, 0, 0, 0, shanghai), time.Date(2019, 6, 2, 8, 0, 0, 0, shanghai))
+	}
+	cron, _ := NewCron("CRON_TZ=Asia/Shanghai 0 0 8 * * *")
+	if next := cron.Next(from); next.Location() != time.UTC {
+		t.Errorf("执行时刻应与给定时间同时区，实际：%v", next.Location())
+	}
+
+	// 半小时时差的时区
+	checkNext(t, "TZ=Asia/Kolkata 0 0 * * * *", time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, 6, 1, 0, 30, 0, 0, time.UTC), time.Date(2019, 6, 1, 1, 30, 0, 0, time.UTC))
+
+	for _, expr := range []string{"CRON_TZ=Mars/Olympus 0 0 8 * * *", "TZ= 0 0 8 * * *", "CRON_TZ=Asia/Shanghai"} {
+		if _, err := NewCron(expr); err == nil {
+			t.Errorf("%s：应解析失败", expr)
+		}
+	}
+
+	schedule, err := NewJobSchedule("0 0 8 * * *", "Asia/Shanghai", "")
+	if err != nil || schedule.(*Cron).Location.String() != shanghai.String() {
+		t.Errorf("作业时区未生效：%v，%v", schedule, err)
+	}
+	schedule, err = NewJobSchedule("TZ=UTC 0 0 8 * * *", "Asia/Shanghai", "")
+	if err != nil || schedule.(*Cron).Location != time.UTC {
+		t.Errorf("调度规则中的时区前缀应优先：%v，%v", schedule, err)
+	}
+}
+
+func TestCronDaylightSaving(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	at := func(month time.Month, d, h, m int, offset int) time.Time { // offset为UTC偏移小时数
+		return time.Date(2019, month, d, h-offset, m, 0, 0, time.UTC).In(ny)
+	}
+
+	// 2019-03-10 02:00 EST跳至03:00 EDT，02:30不存在，当天不执行
+	checkNext(t, "TZ=America/New_York 0 30 2 * * *", at(3, 9, 3, 0, -5),
+		at(3, 11, 2, 30, -4))
+	// 每小时执行的作业跳过不存在的02点
+	checkNext(t, "TZ=America/New_York 0 0 * * * *", at(3, 10, 0, 30, -5),
+		at(3, 10, 1, 0, -5), at(3, 10, 3, 0, -4), at(3, 10, 4, 0, -4))
+
+	// 2019-11-03 02:00 EDT回拨至01:00 EST，01:30出现两次，只在第一次执行
+	checkNext(t, "TZ=America/New_York 0 30 1 * * *", at(11, 3, 0, 0, -4),
+		at(11, 3, 1, 30, -4), at(11, 4, 1, 30, -5))
+	// 小时字段为*时按实际时间执行，重复的时间段执行两次
+	checkNext(t, "TZ=America/New_York 0 0/30 * * * *", at(11, 3, 0, 45, -4),
+		at(11, 3, 1, 0, -4), at(11, 3, 1, 30, -4), at(11, 3, 1, 0, -5), at(11, 3, 1, 30, -5), at(11, 3, 2, 0, -5))
+
+	// 0点不存在的时区：2018-11-04 00:00 BRT跳至01:00 BRST
+	saoPaulo := mustLoadLocation(t, "America/Sao_Paulo")
+	checkNext(t, "TZ=America/Sao_Paulo 0 0 * 4 11 *", time.Date(2018, 11, 3, 12, 0, 0, 0, saoPaulo),
+		time.Date(2018, 11, 4, 1, 0, 0, 0, saoPaulo), time.Date(2018, 11, 4, 2, 0, 0, 0, saoPaulo))
+}
+
+func TestCronDaySpecial(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	from := day(2019, 1, 15)
+
+	// 最后一天，含闰年2月
+	checkNext(t, "0 0 0 L * ?", day(2020, 1, 15), day(2020, 1, 31), day(2020, 2, 29), day(2020, 3, 31))
+	// 最后一个工作日：2019-03-31、2019-08-31分别为周日、周六
+	checkNext(t, "0 0 0 LW 3,8 ?", from, day(2019, 3, 29), day(2019, 8, 30))
+	// 离15日最近的工作日：2019-06-15为周六，2019-09-15为周日
+	checkNext(t, "0 0 0 15W 6,9 ?", from, day(2019, 6, 14), day(2019, 9, 16))
+	// 1日为周六时不跨月，取3日（周一）；2019-06-01为周六
+	checkNext(t, "0 0 0 1W 6 ?", from, day(2019, 6, 3))
+	// 31W在小月不执行
+	checkNext(t, "0 0 0 31W * ?", from, day(2019, 1, 31), day(2019, 3, 29), day(2019, 5, 31))
+	// 最后一个星期五
+	checkNext(t, "0 0 0 ? * 5L", from, day(2019, 1, 25), day(2019, 2, 22), day(2019, 3, 29))
+	checkNext(t, "0 0 0 ? * friL", from, day(2019, 1, 25))
+	// 第二个星期一
+	checkNext(t, "0 0 0 ? * 1#2", from, day(2019, 2, 11), day(2019, 3, 11))
+	checkNext(t, "0 0 0 ? * MON#2,5L", from, day(2019, 1, 25), day(2019, 2, 11), day(2019, 2, 22))
+	// 与普通取值混用
+	checkNext(t, "0 0 0 1,L * ?", from, day(2019, 1, 31), day(2019, 2, 1), day(2019, 2, 28))
+	// 第五个星期四
+	checkNext(t, "0 0 0 ? * 4#5", from, day(2019, 1, 31), day(2019, 5, 30))
+
+	for _, expr := range []string{"0 0 0 32W * ?", "0 0 0 W * ?", "0 0 0 ? * 8L", "0 0 0 ? * 1#6", "0 0 0 ? * 1#2#3",
+		"0 0 0 ? * x#1"} {
+		if _, err := NewCron(expr); err == nil {
+			t.Errorf("%s：应解析失败", expr)
+		}
+	}
+}
+
+func TestCronFormat(t *testing.T) {
+	from := time.Date(2019, 6, 1, 10, 0, 30, 0, time.UTC)
+
+	// 标准crontab的5个字段，秒固定为0
+	checkNext(t, "*/15 10 * * *", from, time.Date(2019, 6, 1, 10, 15, 0, 0, time.UTC))
+	// 7个字段，年份超出5年的查找区间
+	checkNext(t, "0 0 0 1 1 ? 2035", from, time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	checkNext(t, "0 0 12 29 2 ? 2020-2099/4", from,
+		time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC))
+	checkNext(t, "0 0 0 30 2 ? 2020-2060", from, time.Time{})
+	checkNext(t, "0 0 0 1 1 ? 2000,2010", from, time.Time{})
+	checkNext(t, "0 0 0 1 * ? *", from, time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	for _, c := range []struct {
+		expr   string
+		format CronFormat
+		ok     bool
+	}{
+		{"0 0 * * *", StandardCronFormat, true},
+		{"0 0 * * *", SecondsCronFormat, false},
+		{"0 0 0 * * *", StandardCronFormat, false},
+		{"0 0 0 * * * 2030", QuartzCronFormat, true},
+		{"0 0 0 * * *", QuartzCronFormat, false},
+		{"@daily", StandardCronFormat, true},
+		{"0 0 0 * * * 1969", AutoCronFormat, false},
+		{"0 0 0 * * * 2100", AutoCronFormat, false},
+		{"0 0 0 * * * * *", AutoCronFormat, false},
+		{"0 0 0 *", AutoCronFormat, false},
+	} {
+		if _, err := NewCronWithFormat(c.expr, c.format); (err == nil) != c.ok {
+			t.Errorf("%s（格式%d）：期望解析成功%v，错误：%v", c.expr, c.format, c.ok, err)
+		}
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	for _, c := range []struct {
+		expr string
+		from time.Time
+	}{
+		{"0 30 1 * * *", time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/20 * * * * *", time.Date(2019, 12, 31, 23, 59, 0, 0, time.UTC)},
+		{"0 0 0 1 * *", time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 8 LW * ?", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 8 ? * 5L,1#2", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 12 29 2 ? 2020-2099/4", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 30 * * * *", time.Date(2019, 11, 2, 23, 0, 0, 0, newYork)},
+		{"0 30 1,2 * * *", time.Date(2019, 11, 2, 23, 0, 0, 0, newYork)},
+		{"0 30 2 * * *", time.Date(2019, 3, 9, 0, 0, 0, 0, newYork)},
+	} {
+		cron, err := NewCron(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 先用Next正向计算，再用Prev反向计算，两者应一致
+		times := cron.NextN(c.from, 8)
+		if len(times) != 8 {
+			t.Fatalf("%s：执行时刻不足：%v", c.expr, times)
+		}
+		for i := len(times) - 1; i > 0; i-- {
+			if prev := cron.Prev(times[i]); !prev.Equal(times[i-1]) {
+				t.Errorf("%s：%v的上一个执行时刻期望%v，实际%v", c.expr, times[i], times[i-1], prev)
+			}
+			if prev := cron.Prev(times[i].Add(time.Millisecond)); !prev.Equal(times[i]) {
+				t.Errorf("%s：%v的上一个执行时刻期望%v，实际%v", c.expr, times[i].Add(time.Millisecond), times[i], prev)
+			}
+		}
+		if prev := cron.Prev(times[0]); !prev.IsZero() && !prev.Before(c.from.Add(time.Second)) {
+			t.Errorf("%s：%v的上一个执行时刻应不晚于%v，实际%v", c.expr, times[0], c.from, prev)
+		}
+	}
+
+	checkPrev := func(expr string, from, want time.Time) {
+		cron, err := NewCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prev := cron.Prev(from); !prev.Equal(want) {
+			t.Errorf("%s：%v的上一个执行时刻期望%v，实际%v", expr, from, want, prev)
+		}
+	}
+	from := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	checkPrev("0 0 0 1 1 ? 2000", from, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)) // 超出5年
+	checkPrev("0 0 0 1 1 ? 2030", from, time.Time{})
+	checkPrev("0 0 0 30 2 ?", from, time.Time{})
+	checkPrev("TZ=Asia/Shanghai 0 0 8 * * *", from, time.Date(2019, 5, 31, 0, 0, 0, 0, time.UTC))
+}
+
+func TestCronHashed(t *testing.T) {
+	parse := func(expr, seed string) *Cron {
+		t.Helper()
+		cron, err := NewCronWithOptions(expr, CronOptions{Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cron
+	}
+
+	// 同一种子取值稳定，不同种子取值分散
+	spread := make(map[uint64]bool)
+	for i := 0; i < 20; i++ {
+		seed := fmt.Sprintf("job-%d", i)
+		a, b := parse("H H * * * *", seed), parse("H H * * * *", seed)
+		if a.Second != b.Second || a.Minute != b.Minute {
+			t.Errorf("同一种子的取值应相同：%s", seed)
+		}
+		spread[a.Minute] = true
+	}
+	if len(spread) < 5 {
+		t.Errorf("不同种子的取值应分散，实际只有%d种", len(spread))
+	}
+
+	for i := 0; i < 20; i++ {
+		seed := fmt.Sprintf("job-%d", i)
+		c := parse("H(0-29) H/15 * H * ? H(2030-2035)", seed)
+		second := bitValues(c.Second, seconds)
+		minute := bitValues(c.Minute, minutes)
+		dom := bitValues(c.Dom, daysOfMonth)
+		if len(second) != 1 || second[0] > 29 {
+			t.Errorf("H(0-29)取值超出范围：%v", second)
+		}
+		if len(minute) != 4 || minute[0] >= 15 || minute[1]-minute[0] != 15 {
+			t.Errorf("H/15取值不符合预期：%v", minute)
+		}
+		if len(dom) != 1 || dom[0] > 28 {
+			t.Errorf("日字段的H应取1-28：%v", dom)
+		}
+		if len(c.Year) != 1 || c.Year[0] < 2030 || c.Year[0] > 2035 {
+			t.Errorf("H(2030-2035)取值超出范围：%v", c.Year)
+		}
+
+		c = parse("R(10-20) 5,R * * * *", "")
+		second = bitValues(c.Second, seconds)
+		if len(second) != 1 || second[0] < 10 || second[0] > 20 || 1<<5&c.Minute == 0 {
+			t.Errorf("R取值不符合预期：%v %v", second, bitValues(c.Minute, minutes))
+		}
+	}
+
+	// 有种子时R由种子和调度规则原文决定，重新构建作业不会改变取值
+	spread = make(map[uint64]bool)
+	for i := 0; i < 20; i++ {
+		seed := fmt.Sprintf("job-%d", i)
+		a, b := parse("R R * * * *", seed), parse("R R * * * *", seed)
+		if a.Second != b.Second || a.Minute != b.Minute {
+			t.Errorf("同一种子和调度规则的R取值应相同：%s", seed)
+		}
+		spread[a.Minute] = true
+	}
+	if len(spread) < 5 {
+		t.Errorf("不同种子的R取值应分散，实际只有%d种", len(spread))
+	}
+
+	for _, expr := range []string{"H(5) * * * * *", "H(0-29 * * * * *", "Hx * * * * *", "H/0 * * * * *",
+		"H(0-60) * * * * *", "H(0-10/2) * * * * *"} {
+		if _, err := NewCron(expr); err == nil {
+			t.Errorf("%s：期望解析失败", expr)
+		}
+	}
+}
+
+func TestCronWrapRange(t *testing.T) {
+	from := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC) // 星期二
+	day := func(m time.Month, d, h int) time.Time { return time.Date(2019, m, d, h, 0, 0, 0, time.UTC) }
+
+	checkNext(t, "0 0 22-2 * * *", from, day(1, 1, 1), day(1, 1, 2), day(1, 1, 22), day(1, 1, 23), day(1, 2, 0))
+	checkNext(t, "0 0 22-2/2 * * *", from, day(1, 1, 2), day(1, 1, 22), day(1, 2, 0))
+	checkNext(t, "0 0 0 ? * fri-mon", from, day(1, 4, 0), day(1, 5, 0), day(1, 6, 0), day(1, 7, 0), day(1, 11, 0))
+	checkNext(t, "0 0 0 1 nov-feb ?", from, day(2, 1, 0), day(11, 1, 0), day(12, 1, 0))
+	// 星期日可以写作7
+	checkNext(t, "0 0 0 ? * 7", from, day(1, 6, 0))
+	checkNext(t, "0 0 0 ? * 5-7", from, day(1, 4, 0), day(1, 5, 0), day(1, 6, 0), day(1, 11, 0))
+	checkNext(t, "0 0 0 ? * 7L", from, day(1, 27, 0))
+	checkNext(t, "0 0 0 ? * 0-7", from, day(1, 2, 0))
+
+	for _, expr := range []string{"0 50-10 * * * *", "0 0 0 25-5 * ?", "0 0 0 1 1 ? 2030-2020", "H(22-2) * * * * *"} {
+		if _, err := NewCron(expr); err == nil {
+			t.Errorf("%s：只有小时、月和星期允许跨越上限的范围", expr)
+		}
+	}
+	// 7作为带步长的范围的起始取值有歧义，0/2、sun/2仍按周日、二、四、六计算
+	for _, expr := range []string{"0 0 0 ? * 7/2", "0 0 0 ? * 7-3/2"} {
+		if _, err := NewCron(expr); err == nil {
+			t.Errorf("%s：星期日写作7时不能作为带步长的范围的起始取值", expr)
+		}
+	}
+	checkNext(t, "0 0 0 ? * sun/2", from, day(1, 3, 0), day(1, 5, 0), day(1, 6, 0), day(1, 8, 0))
+	checkNext(t, "0 0 0 ? * 5-7/2", from, day(1, 4, 0), day(1, 6, 0), day(1, 11, 0))
+}
+
+func TestCronParseError(t *testing.T) {
+	for _, c := range []struct {
+		expr     string
+		field    string
+		index    int
+		position int
+		value    string
+	}{
+		{"0 60 * * * *", "minute", 1, 2, "60"},
+		{"0  0 8 1,32 * ?", "day_of_month", 3, 9, "32"},
+		{"TZ=UTC 0 0 8 ? * mon,xyz", "day_of_week", 5, 21, "xyz"},
+		{"*/0 8 * * *", "minute", 0, 0, "*/0"},
+		{"0 0 0 1 1 ? 1969", "year", 6, 12, "1969"},
+		{"H(0-99) * * * * *", "second", 0, 0, "H(0-99)"},
+		// 年字段的H、R未指定范围时可能取到过去的年份，作业将不再执行
+		{"0 0 0 1 1 ? H", "year", 6, 12, "H"},
+		{"0 0 0 1 1 ? 2030,R/2", "year", 6, 17, "R/2"},
+		{"0 0 0 *", "", -1, 0, ""},
+		{"TZ=Mars/Olympus 0 0 0 * * *", "", -1, 0, "TZ=Mars/Olympus"},
+	} {
+		_, err := NewCron(c.expr)
+		e, ok := err.(*CronParseError)
+		if !ok {
+			t.Errorf("%s：期望返回*CronParseError，实际：%v", c.expr, err)
+			continue
+		}
+		if e.Expr != c.expr || e.Field != c.field || e.Index != c.index || e.Position != c.position || e.Value != c.value {
+			t.Errorf("%s：错误信息不符合预期：%+v", c.expr, e)
+		}
+		if e.Reason == "" || e.Error() == "" {
+			t.Errorf("%s：缺少错误原因：%+v", c.expr, e)
+		}
+	}
+}
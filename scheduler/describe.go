@@ -0,0 +1,434 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 调度规则描述的语言
+const (
+	ChineseLang = "zh"
+	EnglishLang = "en"
+)
+
+const maxPreview = 100000 // 预览执行时刻时最多计算的次数，防止调度规则过密时长时间阻塞
+
+// 从from之后的n个执行时刻，不足n个时返回全部
+func NextN(schedule Schedule, from time.Time, n int) []time.Time {
+	var times []time.Time
+	for t := schedule.Next(from); !t.IsZero() && len(times) < n; t = schedule.Next(t) {
+		times = append(times, t)
+	}
+	return times
+}
+
+// (from, to]之间的全部执行时刻，最多100000个
+func Between(schedule Schedule, from, to time.Time) []time.Time {
+	var times []time.Time
+	for t := schedule.Next(from); !t.IsZero() && !t.After(to); t = schedule.Next(t) {
+		if len(times) >= maxPreview {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// 从from之后的n个执行时刻
+func (s *Cron) NextN(from time.Time, n int) []time.Time {
+	return NextN(s, from, n)
+}
+
+// (from, to]之间的全部执行时刻
+func (s *Cron) Between(from, to time.Time) []time.Time {
+	return Between(s, from, to)
+}
+
+// 中文描述，例如：每周三 00:00:00
+func (s *Cron) Describe() string {
+	return s.DescribeIn(ChineseLang)
+}
+
+// 按语言描述，lang为EnglishLang时使用英文，否则使用中文
+func (s *Cron) DescribeIn(lang string) string {
+	en := lang == EnglishLang
+	timeText, periodic := s.describeTime(en)
+	dateText := s.describeDate(en, periodic)
+
+	var text string
+	switch {
+	case dateText == "":
+		text = timeText
+	case en:
+		text = timeText + " " + dateText
+	default:
+		text = dateText + " " + timeText
+	}
+	if s.Location != nil {
+		if en {
+			text += fmt.Sprintf(" (%s)", s.Location)
+		} else {
+			text += fmt.Sprintf("（%s时区）", s.Location)
+		}
+	}
+	return text
+}
+
+// 中文描述，例如：每1h30m0s
+func (e *Every) Describe() string {
+	return e.DescribeIn(ChineseLang)
+}
+
+// 按语言描述，lang为EnglishLang时使用英文，否则使用中文
+func (e *Every) DescribeIn(lang string) string {
+	if lang == EnglishLang {
+		return "every " + e.Interval.String()
+	}
+	return "每" + e.Interval.String()
+}
+
+// 字段取值的形式
+const (
+	anyValues   = iota // 全部取值
+	singleValue        // 单个取值
+	rangeValues        // 连续范围
+	everyValues        // 覆盖整个周期的固定步长，例如*/15、5/15
+	stepValues         // 部分范围内的固定步长，例如8-18/2
+	listValues         // 其它取值列表
+)
+
+// 字段的描述用语
+type unitText struct {
+	zhAny, zhEvery, zhSuffix, zhRangeSep string // 每秒、每%d秒、秒（取值的后缀）、-（范围的连接符）
+	enAny, enEvery, enSingular, enPlural string // every second、every %d seconds、second、seconds（取值的前缀）
+	names                                []string
+	enNames                              []string
+	noStep                               bool // 不描述为固定步长，星期跨周时间隔不等，例如周六至周日仅隔1天
+}
+
+var (
+	secondText = &unitText{zhAny: "每秒", zhEvery: "每%d秒", zhSuffix: "秒", zhRangeSep: "-",
+		enAny: "every second", enEvery: "every %d seconds", enSingular: "second", enPlural: "seconds"}
+	minuteText = &unitText{zhAny: "每分钟", zhEvery: "每%d分钟", zhSuffix: "分", zhRangeSep: "-",
+		enAny: "every minute", enEvery: "every %d minutes", enSingular: "minute", enPlural: "minutes"}
+	hourText = &unitText{zhAny: "每小时", zhEvery: "每%d小时", zhSuffix: "点", zhRangeSep: "-",
+		enAny: "every hour", enEvery: "every %d hours", enSingular: "hour", enPlural: "hours"}
+	domText = &unitText{zhAny: "每天", zhEvery: "每%d天", zhSuffix: "日", zhRangeSep: "-",
+		enAny: "every day", enEvery: "every %d days", enSingular: "day", enPlural: "days"}
+	monthText = &unitText{zhAny: "每月", zhEvery: "每%d个月", zhSuffix: "月", zhRangeSep: "-",
+		enAny: "every month", enEvery: "every %d months",
+		enNames: []string{"", "January", "February", "March", "April", "May", "June", "July", "August",
+			"September", "October", "November", "December"}}
+	dowText = &unitText{zhAny: "每天", zhRangeSep: "至", enAny: "every day", noStep: true,
+		names:   []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"},
+		enNames: []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}}
+	yearText = &unitText{zhAny: "每年", zhEvery: "每%d年", zhSuffix: "年", zhRangeSep: "-",
+		enAny: "every year", enEvery: "every %d years"}
+
+	enOrdinals = []string{"", "first", "second", "third", "fourth", "fifth"}
+)
+
+// 位模式中的取值，升序
+func bitValues(bits uint64, r bounds) []int {
+	var values []int
+	for v := r.min; v <= r.max; v++ {
+		if 1<<v&bits > 0 {
+			values = append(values, int(v))
+		}
+	}
+	return values
+}
+
+// 判断取值的形式，返回固定步长
+func valuesKind(values []int, r bounds) (kind, step int) {
+	n := len(values)
+	switch {
+	case n == int(r.max-r.min)+1:
+		return anyValues, 1
+	case n == 1:
+		return singleValue, 0
+	}
+	step = values[1] - values[0]
+	for i := 2; i < n; i++ {
+		if values[i]-values[i-1] != step {
+			return listValues, 0
+		}
+	}
+	switch {
+	case step == 1:
+		return rangeValues, 1
+	case values[0] < int(r.min)+step && int(r.max)-int(r.min)+1-(values[n-1]-values[0]) == step:
+		// 首尾相接后的间隔也等于步长时才是“每隔”，例如小时的0,23不是每23小时
+		return everyValues, step
+	case n > 2:
+		return stepValues, step
+	default:
+		return listValues, 0
+	}
+}
+
+func (u *unitText) name(v int, en bool) string {
+	if en && u.enNames != nil {
+		return u.enNames[v]
+	}
+	if !en && u.names != nil {
+		return u.names[v]
+	}
+	return fmt.Sprint(v)
+}
+
+// 描述取值，返回取值的形式
+func (u *unitText) describe(values []int, r bounds, en bool) (string, int) {
+	kind, step := valuesKind(values, r)
+	if u.noStep && (kind == everyValues || kind == stepValues) {
+		kind = listValues
+	}
+	if kind == anyValues {
+		if en {
+			return u.enAny, kind
+		}
+		return u.zhAny, kind
+	}
+	if len(values) == 0 {
+		return "", listValues
+	}
+
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = u.name(v, en)
+	}
+	first, last := names[0], names[len(names)-1]
+
+	if en {
+		prefix := u.enPlural
+		if kind == singleValue {
+			prefix = u.enSingular
+		}
+		if prefix != "" {
+			prefix += " "
+		}
+		switch kind {
+		case singleValue:
+			return prefix + first, kind
+		case rangeValues:
+			return prefix + first + " through " + last, kind
+		case everyValues:
+			every := fmt.Sprintf(u.enEvery, step)
+			if values[0] != int(r.min) {
+				every += " starting at " + strings.TrimSpace(u.enSingular+" "+first)
+			}
+			return every, kind
+		case stepValues:
+			return fmt.Sprintf(u.enEvery, step) + " in " + prefix + first + " through " + last, kind
+		default:
+			return prefix + strings.Join(names[:len(names)-1], ", ") + " and " + last, kind
+		}
+	}
+
+	switch kind {
+	case singleValue:
+		return first + u.zhSuffix, kind
+	case rangeValues:
+		return first + u.zhRangeSep + last + u.zhSuffix, kind
+	case everyValues:
+		every := fmt.Sprintf(u.zhEvery, step)
+		if values[0] != int(r.min) {
+			every = "从" + first + u.zhSuffix + "起" + every
+		}
+		return every, kind
+	case stepValues:
+		return first + u.zhRangeSep + last + u.zhSuffix + "内" + fmt.Sprintf(u.zhEvery, step), kind
+	default:
+		return strings.Join(names, "、") + u.zhSuffix, kind
+	}
+}
+
+// 描述时分秒，periodic表示是否为每隔一段时间执行（此时省略“每天”）
+func (s *Cron) describeTime(en bool) (string, bool) {
+	type field struct {
+		values []int
+		r      bounds
+		unit   *unitText
+	}
+	fields := []field{
+		{bitValues(s.Hour, hours), hours, hourText},
+		{bitValues(s.Minute, minutes), minutes, minuteText},
+		{bitValues(s.Second, seconds), seconds, secondText},
+	}
+
+	if len(fields[0].values) == 1 && len(fields[1].values) == 1 && len(fields[2].values) == 1 {
+		text := fmt.Sprintf("%02d:%02d:%02d", fields[0].values[0], fields[1].values[0], fields[2].values[0])
+		if en {
+			text = "at " + text
+		}
+		return text, false
+	}
+
+	// 下一个字段为每隔一段时间执行时，省略上级的全部取值，例如*/15 * * -> 每15秒
+	for len(fields) > 1 {
+		kind, _ := valuesKind(fields[0].values, fields[0].r)
+		next, _ := valuesKind(fields[1].values, fields[1].r)
+		if kind != anyValues || (next != anyValues && next != everyValues) {
+			break
+		}
+		fields = fields[1:]
+	}
+
+	var parts []string
+	prevSingle := false
+	for _, f := range fields {
+		text, kind := f.unit.describe(f.values, f.r, en)
+		switch {
+		case en:
+			parts = append([]string{text}, parts...)
+		case len(parts) > 0 && !(prevSingle && kind == singleValue):
+			parts = append(parts, "的"+text)
+		default:
+			parts = append(parts, text)
+		}
+		prevSingle = kind == singleValue
+	}
+	if en {
+		return strings.Join(parts, " of "), true
+	}
+	return strings.Join(parts, ""), true
+}
+
+// 描述年月日和星期，periodic为true且不限制日期时返回""
+func (s *Cron) describeDate(en bool, periodic bool) string {
+	var year, month string
+	if len(s.Year) > 0 {
+		year, _ = yearText.describe(s.Year, years, en)
+	}
+	if s.Month&starBit == 0 {
+		month, _ = monthText.describe(bitValues(s.Month, months), months, en)
+	}
+
+	var dom, dow []string
+	if s.Dom&starBit == 0 {
+		if values := bitValues(s.Dom, daysOfMonth); len(values) > 0 {
+			text, _ := domText.describe(values, daysOfMonth, en)
+			dom = append(dom, text)
+		}
+		dom = append(dom, s.describeDomSpecial(en)...)
+	}
+	if s.Dow&starBit == 0 {
+		if values := bitValues(s.Dow, daysOfWeek); len(values) > 0 {
+			text, _ := dowText.describe(values, daysOfWeek, en)
+			if !en && !strings.HasPrefix(text, "每") {
+				text = "每" + text
+			}
+			dow = append(dow, text)
+		}
+		dow = append(dow, s.describeDowSpecial(en)...)
+	}
+
+	if en {
+		return describeDateEn(year, month, dom, dow, periodic)
+	}
+	return describeDateZh(year, month, dom, dow, periodic)
+}
+
+func describeDateZh(year, month string, dom, dow []string, periodic bool) string {
+	scope := "每月" // 日的范围
+	switch {
+	case year != "" && month != "":
+		scope = year + month
+	case year != "":
+		scope = year + "每月"
+	case month != "":
+		scope = "每年" + month
+	}
+
+	var days []string
+	if len(dom) > 0 {
+		days = append(days, scope+strings.Join(dom, "、"))
+	}
+	if len(dow) > 0 {
+		if year != "" || month != "" {
+			days = append(days, scope+"的"+strings.Join(dow, "、"))
+		} else {
+			for _, text := range dow {
+				if !strings.HasPrefix(text, "每") { // 最后一个周五、第2个周一等
+					text = scope + text
+				}
+				days = append(days, text)
+			}
+		}
+	}
+	if len(days) == 0 {
+		switch {
+		case year == "" && month == "" && periodic:
+			return ""
+		case year == "" && month == "":
+			return "每天"
+		default:
+			return scope + "的每天"
+		}
+	}
+	return strings.Join(days, "或")
+}
+
+func describeDateEn(year, month string, dom, dow []string, periodic bool) string {
+	var days []string
+	if len(dom) > 0 {
+		days = append(days, "on "+strings.Join(dom, ", ")+" of the month")
+	}
+	if len(dow) > 0 {
+		days = append(days, "on "+strings.Join(dow, ", "))
+	}
+	text := strings.Join(days, " or ")
+	if text == "" && !(year == "" && month == "" && periodic) {
+		text = "every day"
+	}
+	for _, scope := range []string{month, year} {
+		switch {
+		case scope == "":
+		case strings.HasPrefix(scope, "every "):
+			text += " " + scope
+		default:
+			text += " in " + scope
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+func (s *Cron) describeDomSpecial(en bool) []string {
+	var texts []string
+	if s.DomLast {
+		texts = append(texts, choose(en, "the last day", "最后一天"))
+	}
+	if s.DomLastWeekday {
+		texts = append(texts, choose(en, "the last weekday", "最后一个工作日"))
+	}
+	for _, day := range bitValues(s.DomWeekday, daysOfMonth) {
+		texts = append(texts, choose(en, fmt.Sprintf("the weekday nearest day %d", day),
+			fmt.Sprintf("离%d日最近的工作日", day)))
+	}
+	return texts
+}
+
+func (s *Cron) describeDowSpecial(en bool) []string {
+	var texts []string
+	for _, dow := range bitValues(s.DowLast, daysOfWeek) {
+		texts = append(texts, choose(en, "the last "+dowText.enNames[dow]+" of the month",
+			"最后一个"+dowText.names[dow]))
+	}
+	for dow := 0; dow <= 6; dow++ {
+		for nth := 1; nth <= 5; nth++ {
+			if 1<<uint(dow*8+nth)&s.DowNth > 0 {
+				texts = append(texts, choose(en, "the "+enOrdinals[nth]+" "+dowText.enNames[dow]+" of the month",
+					fmt.Sprintf("第%d个%s", nth, dowText.names[dow])))
+			}
+		}
+	}
+	return texts
+}
+
+func choose(en bool, enText, zhText string) string {
+	if en {
+		return enText
+	}
+	return zhText
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	for _, c := range []struct {
+		rule, zh, en string
+	}{
+		{"0 0 0 ? * wed", "每周三 00:00:00", "at 00:00:00 on Wednesday"},
+		{"*/15 * * * * *", "每15秒", "every 15 seconds"},
+		{"0 */5 * * * *", "每5分钟的0秒", "second 0 of every 5 minutes"},
+		{"0 30 8-18 * * 1-5", "每周一至周五 8-18点的30分0秒",
+			"second 0 of minute 30 of hours 8 through 18 on Monday through Friday"},
+		{"0 0 0 1,15 * ?", "每月1、15日 00:00:00", "at 00:00:00 on days 1 and 15 of the month"},
+		{"0 0 8 L * ?", "每月最后一天 08:00:00", "at 08:00:00 on the last day of the month"},
+		{"0 0 8 ? * 1#2", "每月第2个周一 08:00:00", "at 08:00:00 on the second Monday of the month"},
+		{"0 0 0 1 * 1", "每月1日或每周一 00:00:00", "at 00:00:00 on day 1 of the month or on Monday"},
+		{"0 0 0 1 1 ? 2030", "2030年1月1日 00:00:00", "at 00:00:00 on day 1 of the month in January in 2030"},
+		{"TZ=Asia/Shanghai 0 0 8 * * *", "每天 08:00:00（Asia/Shanghai时区）",
+			"at 08:00:00 every day (Asia/Shanghai)"},
+		{"@every 1h30m", "每1h30m0s", "every 1h30m0s"},
+		// 星期按名称列出，不描述为固定步长
+		{"0 0 0 ? * sun,sat", "每周日、周六 00:00:00", "at 00:00:00 on Sunday and Saturday"},
+		{"0 0 0 ? * 6-0", "每周日、周六 00:00:00", "at 00:00:00 on Sunday and Saturday"},
+		{"0 0 0 ? * 0,2,4,6", "每周日、周二、周四、周六 00:00:00",
+			"at 00:00:00 on Sunday, Tuesday, Thursday and Saturday"},
+		{"0 0 0 ? * 1,3,5", "每周一、周三、周五 00:00:00", "at 00:00:00 on Monday, Wednesday and Friday"},
+		{"0 0 0 ? * 1-5/2", "每周一、周三、周五 00:00:00", "at 00:00:00 on Monday, Wednesday and Friday"},
+		{"0 0 0,23 * * *", "0、23点的0分0秒", "second 0 of minute 0 of hours 0 and 23"},
+		{"0 0 0,12 * * *", "每12小时的0分0秒", "second 0 of minute 0 of every 12 hours"},
+		{"0 0 0 1 1,12 *", "每年1、12月1日 00:00:00", "at 00:00:00 on day 1 of the month in January and December"},
+		{"0 0 0 1 12-1 *", "每年1、12月1日 00:00:00", "at 00:00:00 on day 1 of the month in January and December"},
+	} {
+		schedule, err := NewSchedule(c.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zh := schedule.DescribeIn(ChineseLang); zh != c.zh {
+			t.Errorf("%s：中文描述期望“%s”，实际“%s”", c.rule, c.zh, zh)
+		}
+		if en := schedule.DescribeIn(EnglishLang); en != c.en {
+			t.Errorf("%s：英文描述期望“%s”，实际“%s”", c.rule, c.en, en)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	cron, err := NewCron("0 0 0 1 * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)
+	next := cron.NextN(from, 3)
+	if len(next) != 3 || !next[2].Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("NextN不符合预期：%v", next)
+	}
+	between := cron.Between(from, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC))
+	if len(between) != 3 || !between[0].Equal(next[0]) {
+		t.Errorf("Between应包含结束时刻：%v", between)
+	}
+
+	cron, err = NewCron("0 0 0 1 1 ? 2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := cron.NextN(from, 3); len(next) != 1 {
+		t.Errorf("执行时刻不足时应返回全部：%v", next)
+	}
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 调度器指令执行失败时返回的错误，调用方可直接与之比较
+var (
+	ErrSchedulerNotRunning     = errors.New("调度器未启动")
+	ErrSchedulerAlreadyRunning = errors.New("重复启动调度器")
+	ErrSchedulerRunning        = errors.New("调度器运行中")
+	ErrSchedulerClosed         = errors.New("调度器已关闭")
+	ErrStoreNotConfigured      = errors.New("未指定持久化且MySQL数据库未初始化")
+	ErrJobNotFound             = errors.New("作业不存在")
+	ErrJobAlreadyOpened        = errors.New("重复开启作业")
+	ErrJobAlreadyClosed        = errors.New("重复关闭作业")
+	ErrJobNotExecuting         = errors.New("作业没有正在进行的执行")
+)
+
+// 构建作业失败（调度规则或爬虫名称非法）
+type JobBuildError struct {
+	Err error // 原始错误
+}
+
+func (e *JobBuildError) Error() string {
+	return "构建作业失败，" + e.Err.Error()
+}
+
+func (e *JobBuildError) Unwrap() error {
+	return e.Err
+}
+
+// 数据库操作失败
+type DatabaseError struct {
+	Op  string // 操作名称，例如“插入作业”
+	Err error  // 原始错误
+}
+
+func (e *DatabaseError) Error() string {
+	return "执行数据库" + e.Op + "失败，" + e.Err.Error()
+}
+
+func (e *DatabaseError) Unwrap() error {
+	return e.Err
+}
+
+// 执行按作业的并发策略被跳过，已记录SKIPPED执行记录
+type JobSkippedError struct {
+	ResultId string // SKIPPED执行记录的ID
+	Reason   string // 跳过原因
+}
+
+func (e *JobSkippedError) Error() string {
+	return e.Reason
+}
+
+// Cron表达式解析失败，Field和Position用于定位出错的字段
+type CronParseError struct {
+	Expr     string // 完整的表达式
+	Field    string // 出错的字段：second、minute、hour、day_of_month、month、day_of_week、year，表达式整体出错时为空
+	Index    int    // 出错的字段在表达式中的序号，从0开始，表达式整体出错时为-1
+	Position int    // 出错部分在表达式中的起始位置（字节偏移，从0开始）
+	Value    string // 出错的部分
+	Reason   string // 出错原因
+}
+
+func (e *CronParseError) Error() string {
+	switch {
+	case e.Expr == "":
+		return e.Reason
+	case e.Field == "":
+		return fmt.Sprintf("%s，表达式：\"%s\"", e.Reason, e.Expr)
+	}
+	var name string
+	for _, f := range cronFields {
+		if f.name == e.Field {
+			name = f.zh
+		}
+	}
+	return fmt.Sprintf("%s，字段：%s（第%d个），位置：%d，内容：\"%s\"，表达式：\"%s\"",
+		e.Reason, name, e.Index+1, e.Position, e.Value, e.Expr)
+}
@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/xnffdd/gospider/logs"
+	"time"
+)
+
+// 工作协程限制，到达执行时刻的作业超过限制时排队等待工作协程，等待重试期间仍占用工作协程
+type WorkerLimits struct {
+	Global  int            // 同时执行的作业总数上限，<=0表示不限制
+	Runners map[string]int // 按爬虫名称限制同时执行的数量，例如{"WeiXinArticle": 2}，<=0或未配置表示不限制
+}
+
+func (l WorkerLimits) copy() WorkerLimits {
+	runners := make(map[string]int, len(l.Runners))
+	for name, limit := range l.Runners {
+		runners[name] = limit
+	}
+	return WorkerLimits{Global: l.Global, Runners: runners}
+}
+
+// 排队等待工作协程的执行
+type PendingExecution struct {
+	ResultId    string        // 开始执行后使用的执行记录ID
+	JobId       string        // 作业ID
+	JobName     string        // 作业名称
+	RunnerName  string        // 爬虫名称
+	EnqueueTime time.Time     // 入队时间
+	Wait        time.Duration // 截至快照时已等待的时间
+}
+
+// 执行器快照
+type ExecutorSnapshot struct {
+	Limits         WorkerLimits        // 工作协程限制
+	Running        int                 // 占用工作协程的执行数量
+	RunningRunners map[string]int      // 各爬虫占用工作协程的执行数量
+	Pending        []*PendingExecution // 排队等待工作协程的执行，按入队先后排列
+}
+
+// 执行中的作业，包括排队等待工作协程的执行
+type execution struct {
+	jobId       string             // 作业ID
+	job         *Job               // 作业快照
+	result      *JobResult         // 首次尝试的执行记录
+	ctx         context.Context    // 执行的上下文，终止或中断时取消
+	cancel      context.CancelFunc // 中断执行
+	interrupted chan struct{}      // 调度器关闭时先关闭此通道再中断执行，执行记录状态为INTERRUPTED
+	enqueueTime time.Time          // 入队时间
+	started     bool               // 是否已占用工作协程开始执行
+}
+
+type interruptedKey struct{}
+
+// 执行是否因调度器关闭而被中断
+func interrupted(ctx context.Context) bool {
+	ch, ok := ctx.Value(interruptedKey{}).(chan struct{})
+	if !ok {
+		return false
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// 调度器关闭时中断执行，重复调用无影响
+func (e *execution) interrupt() {
+	select {
+	case <-e.interrupted:
+	default:
+		close(e.interrupted)
+	}
+	e.cancel()
+}
+
+// 启动作业执行，返回执行记录ID，作业被终止时通过ctx通知运行函数，失败时按作业的重试策略重试
+// 没有空闲的工作协程时排队等待，排队期间作业仍视为执行中
+func (s *Scheduler) launch(job *Job) string {
+	e := &execution{jobId: job.Id, job: job, interrupted: make(chan struct{}), enqueueTime: s.clock.Now()}
+	e.ctx, e.cancel = context.WithCancel(context.WithValue(context.Background(), interruptedKey{}, e.interrupted))
+	e.result = newJobResult(job, s.store, s.clock, s.instanceId)
+	s.executions[e.result.id] = e
+	s.pending = append(s.pending, e)
+	s.startPending()
+	if !e.started {
+		logs.InfoLogger.Printf("没有空闲的工作协程，排队等待，作业ID：%s，执行记录ID：%s，排队中%d个",
+			job.Id, e.result.id, len(s.pending))
+	}
+	return e.result.id
+}
+
+// 是否有空闲的工作协程执行指定爬虫
+func (s *Scheduler) available(runnerName string) bool {
+	if s.limits.Global > 0 && s.workers >= s.limits.Global {
+		return false
+	}
+	limit := s.limits.Runners[runnerName]
+	return limit <= 0 || s.runningRunners[runnerName] < limit
+}
+
+// 按入队顺序启动有空闲工作协程的执行，受爬虫限制而等待的执行不阻塞其后其他爬虫的执行
+func (s *Scheduler) startPending() {
+	var pending []*execution
+	for _, e := range s.pending {
+		if s.available(e.job.RunnerName) {
+			s.run(e)
+		} else {
+			pending = append(pending, e)
+		}
+	}
+	s.pending = pending
+}
+
+// 占用工作协程开始执行，执行结束后通过finished通知listen协程
+func (s *Scheduler) run(e *execution) {
+	e.started = true
+	e.result.queueWait = s.clock.Now().Sub(e.enqueueTime)
+	s.workers++
+	s.runningRunners[e.job.RunnerName]++
+	go func() {
+		s.execute(e.ctx, e.job, e.result)
+		e.cancel()
+		select {
+		case s.finished <- e.result.id:
+		case <-s.closed: // 调度器已关闭，不再跟踪执行
+		}
+	}()
+}
+
+// 执行结束，释放工作协程
+func (s *Scheduler) release(e *execution) {
+	delete(s.executions, e.result.id)
+	if !e.started {
+		return
+	}
+	s.workers--
+	s.runningRunners[e.job.RunnerName]--
+	if s.runningRunners[e.job.RunnerName] == 0 {
+		delete(s.runningRunners, e.job.RunnerName)
+	}
+}
+
+// 放弃排队等待工作协程的执行，在后台以指定状态记录执行记录
+func (s *Scheduler) dropPending(e *execution, state, log string) {
+	for i, p := range s.pending {
+		if p == e {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			break
+		}
+	}
+	e.cancel()
+	s.release(e)
+	e.result.queueWait = s.clock.Now().Sub(e.enqueueTime)
+	s.saveInBackground(e.result, func() error { return e.result.saveUnstarted(state, log) })
+	logs.InfoLogger.Printf("%s，作业ID：%s，执行记录ID：%s", log, e.jobId, e.result.id)
+}
+
+// 在后台协程中保存执行记录，不阻塞listen协程，调度器关闭前等待全部保存结束
+func (s *Scheduler) saveInBackground(result *JobResult, save func() error) {
+	s.saving.Add(1)
+	go func() {
+		defer s.saving.Done()
+		if err := save(); err != nil {
+			logs.ErrorLogger.Printf("保存执行结果到数据库时发生错误，作业ID：%s，执行记录ID：%s，%s",
+				result.jobId, result.id, err.Error())
+		}
+	}()
+}
+
+// 全部执行结束后，通知等待的指令
+func (s *Scheduler) notifyDrained() {
+	if len(s.executions) > 0 {
+		return
+	}
+	for _, waiter := range s.drainWaiters {
+		waiter.done(nil, nil)
+	}
+	s.drainWaiters = nil
+}
+
+func (s *Scheduler) processSetLimitsCMD(limits WorkerLimits) {
+	s.limits = limits.copy()
+	s.startPending()
+}
+
+func (s *Scheduler) snapshotExecutor() *ExecutorSnapshot {
+	now := s.clock.Now()
+	snapshot := &ExecutorSnapshot{
+		Limits:         s.limits.copy(),
+		Running:        s.workers,
+		RunningRunners: make(map[string]int, len(s.runningRunners)),
+		Pending:        []*PendingExecution{},
+	}
+	for name, count := range s.runningRunners {
+		snapshot.RunningRunners[name] = count
+	}
+	for _, e := range s.pending {
+		snapshot.Pending = append(snapshot.Pending, &PendingExecution{
+			ResultId:    e.result.id,
+			JobId:       e.jobId,
+			JobName:     e.job.Name,
+			RunnerName:  e.job.RunnerName,
+			EnqueueTime: e.enqueueTime,
+			Wait:        now.Sub(e.enqueueTime),
+		})
+	}
+	return snapshot
+}
+
+// 更新工作协程限制，阻塞调用，更新后立即启动有空闲工作协程的排队中的执行，已在执行的不受影响
+func (s *Scheduler) SetWorkerLimits(ctx context.Context, limits WorkerLimits) error {
+	l := limits.copy()
+	_, err := s.sendCMD(ctx, s.setLimits, &command{limits: &l})
+	return err
+}
+
+// 执行器快照，阻塞调用，调度器已关闭时返回nil
+func (s *Scheduler) Executor() *ExecutorSnapshot {
+	select {
+	case s.executorSnapshot <- nil:
+		return <-s.executorSnapshot
+	case <-s.closed:
+		return nil
+	}
+}
+
+// 更新默认调度器的工作协程限制，阻塞调用
+func ExecCMDSetWorkerLimits(ctx context.Context, limits WorkerLimits) error {
+	return Default().SetWorkerLimits(ctx, limits)
+}
+
+func GetExecutorSnapshot() *ExecutorSnapshot { // 阻塞调用
+	return Default().Executor()
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExecutorLimits(t *testing.T) {
+	s, clock := startTestScheduler(t)
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	limits := WorkerLimits{Global: 2, Runners: map[string]int{testBlockRunnerName: 1}}
+	if err := s.SetWorkerLimits(ctx, limits); err != nil {
+		t.Fatal(err)
+	}
+	limits.Runners[testBlockRunnerName] = 5 // 限制以副本保存，不受调用方修改影响
+
+	newJob := func(runnerName, args string) *Job {
+		t.Helper()
+		job, err := s.NewJob(ctx, &JobCore{Name: args, CronRule: "@daily", RunnerName: runnerName, RunnerArgs: args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+	trigger := func(job *Job) string {
+		t.Helper()
+		id, err := s.TriggerJob(ctx, job.Id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	lastResult := func(job *Job) *Result {
+		t.Helper()
+		results, _, err := s.QueryResults(ctx, &ResultQuery{JobId: job.Id, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			return nil
+		}
+		return results[0]
+	}
+	a := newJob(testBlockRunnerName, "a")
+	b := newJob(testBlockRunnerName, "b")
+	c := newJob(testRunnerName, "c")
+
+	// 按爬虫限制排队，不阻塞其他爬虫
+	trigger(a)
+	waitRun(t, "a")
+	bId := trigger(b)
+	trigger(c)
+	waitRun(t, "c")
+	waitFor(t, "c执行结束", func() bool { return s.Executor().Running == 1 })
+	snapshot := s.Executor()
+	if len(snapshot.Pending) != 1 || snapshot.Pending[0].ResultId != bId || snapshot.Pending[0].RunnerName != testBlockRunnerName ||
+		snapshot.RunningRunners[testBlockRunnerName] != 1 || snapshot.Limits.Runners[testBlockRunnerName] != 1 {
+		t.Fatalf("执行器快照不符合预期：%+v", snapshot)
+	}
+
+	// 工作协程空闲后启动排队中的执行，并记录排队时间
+	clock.Advance(3 * time.Second)
+	testRelease <- struct{}{}
+	waitRun(t, "b")
+	if r := lastResult(b); r == nil || r.Id != bId || r.QueueWait != 3*time.Second {
+		t.Errorf("排队等待时间不符合预期：%+v", r)
+	}
+
+	// 全局限制
+	if err := s.SetWorkerLimits(ctx, WorkerLimits{Global: 1}); err != nil {
+		t.Fatal(err)
+	}
+	trigger(c)
+	aId := trigger(a)
+	expectNoRun(t)
+	if snapshot := s.Executor(); snapshot.Running != 1 || len(snapshot.Pending) != 2 {
+		t.Fatalf("执行器快照不符合预期：%+v", snapshot)
+	}
+
+	// 终止排队中的执行
+	if _, err := s.KillJob(ctx, a.Id); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "排队期间被终止的执行记录为CANCELLED", func() bool {
+		r := lastResult(a)
+		return r != nil && r.Id == aId && r.ExecuteState == CancelledJobExecuteState
+	})
+	testRelease <- struct{}{}
+	waitRun(t, "c")
+	waitFor(t, "全部执行结束", func() bool {
+		snapshot := s.Executor()
+		return snapshot.Running == 0 && len(snapshot.Pending) == 0
+	})
+}
+
+func TestExecutorTimeoutAndKill(t *testing.T) {
+	s, _ := startTestScheduler(t)
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	state := func(resultId string) string {
+		results, _, err := s.QueryResults(ctx, &ResultQuery{OriginId: resultId})
+		if err != nil || len(results) != 1 {
+			return ""
+		}
+		return results[0].ExecuteState
+	}
+
+	// 超过作业的超时时间被中断，记录为TIMEOUT
+	job, err := s.NewJob(ctx, &JobCore{Name: "timeout", CronRule: "@daily", RunnerName: testBlockRunnerName,
+		RunnerArgs: "timeout", Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultId, err := s.TriggerJob(ctx, job.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "timeout")
+	waitFor(t, "执行超时", func() bool { return state(resultId) == TimeoutJobExecuteState })
+
+	// 运行中被终止，记录为CANCELLED
+	job, err = s.NewJob(ctx, &JobCore{Name: "kill", CronRule: "@daily", RunnerName: testBlockRunnerName,
+		RunnerArgs: "kill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultId, err = s.TriggerJob(ctx, job.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "kill")
+	if _, err = s.KillJob(ctx, job.Id); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "执行被终止", func() bool { return state(resultId) == CancelledJobExecuteState })
+}
@@ -10,23 +10,31 @@ import (
 
 // 作业核心字段
 type JobCore struct {
-	Id         string // 作业唯一ID，默认""，对应MySQL的job表id字段
-	Name       string // 作业名称（昵称），默认""，对应MySQL的job表name字段
-	CronRule   string // 调度时间规则，默认""，对应MySQL的job表cron_rule字段
-	RunnerName string // 作业函数名称，默认""，对应MySQL的job表runner_name字段
-	RunnerArgs string // 作业函数参数，引用类型，默认nil，对应MySQL的job表runner_args字段
+	Id         string        // 作业唯一ID，默认""，对应MySQL的job表id字段
+	Name       string        // 作业名称（昵称），默认""，对应MySQL的job表name字段
+	CronRule   string        // 调度时间规则，默认""，对应MySQL的job表cron_rule字段
+	RunnerName string        // 作业函数名称，默认""，对应MySQL的job表runner_name字段
+	RunnerArgs string        // 作业函数参数，引用类型，默认nil，对应MySQL的job表runner_args字段
+	Timeout    time.Duration // 单次执行超时时间，默认0表示不限制，对应MySQL的job表timeout字段（秒）
+	TimeZone   string        // 调度规则的IANA时区，例如Asia/Shanghai，默认""表示服务器时区，调度规则中的CRON_TZ=前缀优先，对应MySQL的job表time_zone字段
+
+	ConcurrencyPolicy string        // 并发策略，默认""等同于ALLOW，对应MySQL的job表concurrency_policy字段
+	Retry             RetryPolicy   // 重试策略，默认不重试，对应MySQL的job表retry_*字段
+	Misfire           MisfirePolicy // 错过执行的处理策略，默认忽略，对应MySQL的job表misfire_*字段
 }
 
 // 作业
 type Job struct {
 	JobCore
-	CreateTime time.Time          // 创建时间，默认time.Time{}：IsZero()->true，对应MySQL的job表ctime字段
-	UpdateTime time.Time          // 修改时间，默认time.Time{}：IsZero()->true，对应MySQL的job表utime字段
-	Deleted    bool               // 是否删除，默认false，对应MySQL的job表deleted字段，软删除
-	Opened     bool               // 是否启用，默认false，对应MySQL的job表opened字段
-	Runner     func(string) error // 运行函数，引用类型，默认nil
-	Cron       *Cron              // 调度时间计算器，引用类型，默认nil
-	Next       time.Time          // 下一次执行时间，根据Cron调度规则计算，默认time.Time{}：IsZero()->true
+	CreateTime time.Time      // 创建时间，默认time.Time{}：IsZero()->true，对应MySQL的job表ctime字段
+	UpdateTime time.Time      // 修改时间，默认time.Time{}：IsZero()->true，对应MySQL的job表utime字段
+	Deleted    bool           // 是否删除，默认false，对应MySQL的job表deleted字段，软删除
+	Opened     bool           // 是否启用，默认false，对应MySQL的job表opened字段
+	Runner     spiders.Runner // 运行函数，引用类型，默认nil
+	Cron       Schedule       // 调度时间计算器，Cron表达式或@every固定间隔，默认nil
+	Next       time.Time      // 下一次执行时间，根据Cron调度规则计算，默认time.Time{}：IsZero()->true
+
+	trigger *Trigger // 手动触发的参数，定时执行时为nil
 }
 
 // 按下一次执行时间Next排序
@@ -53,140 +61,111 @@ func (job *Job) String() string {
 		"\n\t是否删除:%v"+
 		"\n\t是否开启:%v"+
 		"\n\t调度规则:%v"+
+		"\n\t时区:%v"+
 		"\n\t调度时间:%v"+
 		"\n\t爬虫名称:%v"+
-		"\n\t爬虫参数:%v\n",
+		"\n\t爬虫参数:%v"+
+		"\n\t超时时间:%v"+
+		"\n\t并发策略:%v"+
+		"\n\t重试策略:%+v"+
+		"\n\t错过执行:%+v\n",
 		job.Id, job.Name, job.CreateTime, job.UpdateTime,
-		job.Deleted, job.Opened, job.CronRule, job.Cron.Next(time.Now()), job.RunnerName, job.RunnerArgs)
+		job.Deleted, job.Opened, job.CronRule, job.TimeZone, job.Cron.Next(time.Now()), job.RunnerName, job.RunnerArgs, job.Timeout,
+		job.ConcurrencyPolicy, job.Retry, job.Misfire)
 }
 
-func (job *Job) build() error {
-	cron, err := NewCron(job.CronRule)
+// 调度器可用的爬虫
+type RunnerLookup interface {
+	GetRunnerByName(name string) (spiders.Runner, error) // 按名称查找爬虫运行函数
+	GetRunnerNames() []string                            // 全部爬虫名称
+}
+
+type globalRunners struct{}
+
+func (globalRunners) GetRunnerByName(name string) (spiders.Runner, error) {
+	return spiders.GetRunnerByName(name)
+}
+
+func (globalRunners) GetRunnerNames() []string {
+	return spiders.GetRunnerNames()
+}
+
+// 全局注册的爬虫，即spiders.Register注册的爬虫
+var GlobalRunners RunnerLookup = globalRunners{}
+
+// 不注册到全局、只供指定调度器使用的爬虫，键为爬虫名称
+type RunnerMap map[string]spiders.Runner
+
+func (m RunnerMap) GetRunnerByName(name string) (spiders.Runner, error) {
+	runner, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("爬虫不存在，名称：%v", name)
+	}
+	return runner, nil
+}
+
+func (m RunnerMap) GetRunnerNames() []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (job *Job) build(lookup RunnerLookup) error {
+	cron, err := NewJobSchedule(job.CronRule, job.TimeZone, job.Id)
 	if err != nil {
 		return err
 	}
-	runner, err := spiders.GetRunnerByName(job.RunnerName)
+	runner, err := lookup.GetRunnerByName(job.RunnerName)
 	if err != nil {
 		return err
 	}
+	if err = CheckConcurrencyPolicy(job.ConcurrencyPolicy); err != nil {
+		return err
+	}
+	if err = job.Retry.Check(); err != nil {
+		return err
+	}
+	if err = job.Misfire.Check(); err != nil {
+		return err
+	}
 	job.Cron = cron
 	job.Runner = runner
 	return nil
 }
 
+// 从database.MySQL加载作业并构建，构建失败的作业被忽略，需事先调用database.InitMySQL
 func LoadJobs() ([]*Job, error) {
-	var jobs []*Job
-
-	sql := "select id,ctime,utime,deleted,name,cron_rule,opened,runner_name,runner_args from job where deleted=?"
-
-	rows, err := database.MySQL.Query(sql, false)
+	jobs, err := NewMySQLStore(database.MySQL).LoadJobs()
 	if err != nil {
 		return nil, err
 	}
+	return buildJobs(jobs, GlobalRunners), nil
+}
 
-	for rows.Next() {
-		job := &Job{}
-		if err = rows.Scan(&job.Id, &job.CreateTime, &job.UpdateTime, &job.Deleted, &job.Name, &job.CronRule,
-			&job.Opened, &job.RunnerName, &job.RunnerArgs); err != nil {
-			return nil, err
-		}
-		err = job.build()
+// 构建作业，构建失败的作业记录日志后被忽略
+func buildJobs(jobs []*Job, lookup RunnerLookup) []*Job {
+	var built []*Job
+	for _, job := range jobs {
+		err := job.build(lookup)
 		if err != nil {
-			logs.ErrorLogger.Printf("构建作业失败，%s", err.Error())
+			logs.ErrorLogger.Printf("构建作业失败，作业ID：%s，%s", job.Id, err.Error())
 			continue
 		}
-		jobs = append(jobs, job)
+		built = append(built, job)
 	}
-
-	return jobs, nil
+	return built
 }
 
 func DeleteJob(job *Job) (affect int64, err error) {
-	sql := "update job set utime=?,deleted=? where id=?"
-
-	stmt, err := database.MySQL.Prepare(sql)
-	if err != nil {
-		return
-	}
-
-	t := time.Now()
-	res, err := stmt.Exec(t, true, job.Id)
-	if err != nil {
-		return
-	}
-
-	affect, err = res.RowsAffected()
-	if err != nil {
-		return
-	}
-
-	err = stmt.Close()
-	if err != nil {
-		return
-	}
-
-	job.Deleted = true
-	job.UpdateTime = t
-
-	return
+	return NewMySQLStore(database.MySQL).DeleteJob(job, time.Now())
 }
 
 func InsertJob(job *Job) (affect int64, err error) {
-	sql := "insert into job(id,ctime,utime,deleted,name,cron_rule,opened,runner_name,runner_args) values(?,?,?,?,?,?,?,?,?)"
-
-	stmt, err := database.MySQL.Prepare(sql)
-
-	if err != nil {
-		return
-	}
-
-	t := time.Now()
-	res, err := stmt.Exec(job.Id, t, t, job.Deleted, job.Name, job.CronRule, job.Opened, job.RunnerName, job.RunnerArgs)
-	if err != nil {
-		return
-	}
-
-	affect, err = res.RowsAffected()
-	if err != nil {
-		return
-	}
-
-	err = stmt.Close()
-	if err != nil {
-		return
-	}
-
-	job.CreateTime = t
-	job.UpdateTime = t
-
-	return
+	return NewMySQLStore(database.MySQL).InsertJob(job, time.Now())
 }
 
 func UpdateJob(job *Job) (affect int64, err error) {
-	sql := "update job set utime=?,name=?,cron_rule=?,opened=?,runner_name=?,runner_args=? where id=?"
-
-	stmt, err := database.MySQL.Prepare(sql)
-	if err != nil {
-		return
-	}
-
-	t := time.Now()
-	res, err := stmt.Exec(t, job.Name, job.CronRule, job.Opened, job.RunnerName, job.RunnerArgs, job.Id)
-	if err != nil {
-		return
-	}
-
-	affect, err = res.RowsAffected()
-	if err != nil {
-		return
-	}
-
-	err = stmt.Close()
-	if err != nil {
-		return
-	}
-
-	job.UpdateTime = t
-
-	return
+	return NewMySQLStore(database.MySQL).UpdateJob(job, time.Now())
 }
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/xnffdd/gospider/logs"
+	"time"
+)
+
+// 错过执行时刻（停机、调度器停止或定时器严重延迟）后的处理方式，对应MySQL的job表misfire_policy字段
+const (
+	IgnoreMisfirePolicy = "IGNORE" // 忽略错过的执行
+	OnceMisfirePolicy   = "ONCE"   // 错过的执行合并为一次立即执行
+	AllMisfirePolicy    = "ALL"    // 逐个补执行错过的执行，最多Limit次，上一次执行结束后依次执行
+)
+
+const (
+	defaultMisfireGrace = time.Minute
+	defaultMisfireLimit = 10
+)
+
+// 错过执行的处理策略
+type MisfirePolicy struct {
+	Policy string        // 处理方式，默认""等同于IGNORE，对应MySQL的job表misfire_policy字段
+	Limit  int           // ALL方式最多补执行的次数，<=0时取默认值10，对应MySQL的job表misfire_limit字段
+	Grace  time.Duration // 宽限时间，迟到不超过宽限时间的执行视为按时执行，<=0时取默认值1分钟，对应MySQL的job表misfire_grace字段（秒）
+}
+
+func misfirePolicyOrDefault(policy string) string {
+	if policy == "" {
+		return IgnoreMisfirePolicy
+	}
+	return policy
+}
+
+// 校验错过执行的处理策略
+func (p *MisfirePolicy) Check() error {
+	switch p.Policy {
+	case "", IgnoreMisfirePolicy, OnceMisfirePolicy, AllMisfirePolicy:
+		return nil
+	default:
+		return fmt.Errorf("错过执行的处理方式非法，取值：%s，可选：%s、%s、%s", p.Policy,
+			IgnoreMisfirePolicy, OnceMisfirePolicy, AllMisfirePolicy)
+	}
+}
+
+func (p *MisfirePolicy) grace() time.Duration {
+	if p.Grace <= 0 {
+		return defaultMisfireGrace
+	}
+	return p.Grace
+}
+
+func (p *MisfirePolicy) limit() int {
+	if p.Limit <= 0 {
+		return defaultMisfireLimit
+	}
+	return p.Limit
+}
+
+// 按处理方式计算需要补执行的次数
+func (p *MisfirePolicy) runs(onTime bool, missed int) int {
+	switch {
+	case missed == 0:
+		return 0
+	case p.Policy == OnceMisfirePolicy:
+		if onTime { // 已被按时的执行覆盖
+			return 0
+		}
+		return 1
+	case p.Policy == AllMisfirePolicy:
+		if missed > p.limit() {
+			return p.limit()
+		}
+		return missed
+	default:
+		return 0
+	}
+}
+
+// 一段时间内应执行的执行时刻，调度规则过密时不逐个保存，只记录最早、最晚的执行时刻和数量
+type dueTimes struct {
+	first time.Time // 最早的执行时刻
+	last  time.Time // 最晚的执行时刻
+	count int       // 执行时刻的数量，最多统计到上限
+}
+
+// (from, to]之间应执行的执行时刻，数量最多统计到max
+func dueBetween(schedule Schedule, from, to time.Time, max int) dueTimes {
+	first := schedule.Next(from)
+	if first.IsZero() || first.After(to) {
+		return dueTimes{}
+	}
+	due := dueTimes{first: first}
+	switch s := schedule.(type) {
+	case *Cron:
+		due.last = s.Prev(to.Add(time.Nanosecond))
+	case *Every:
+		due.last = first.Add(to.Sub(first) / s.Interval * s.Interval)
+	default:
+		times := Between(schedule, from, to)
+		due.last = times[len(times)-1]
+	}
+	if due.last.Before(first) {
+		due.last = first
+	}
+	for t := first; !t.IsZero() && !t.After(to) && due.count < max; t = schedule.Next(t) {
+		due.count++
+	}
+	return due
+}
+
+// 定时器到达时应执行的执行时刻：作业的下一次执行时刻next，以及(next, now]之间错过的执行时刻
+func dueSince(schedule Schedule, next, now time.Time, max int) dueTimes {
+	due := dueBetween(schedule, next, now, max-1)
+	due.first = next
+	due.count++
+	if due.last.IsZero() {
+		due.last = next
+	}
+	return due
+}
+
+// 按宽限时间处理应执行的执行时刻：按时的执行合并为一次执行，错过的执行按作业的处理策略补执行
+// due的数量应至少统计到Limit+1，以便区分错过的次数是否超过Limit
+func (s *Scheduler) fire(job *Job, due dueTimes, now time.Time) {
+	if due.count == 0 {
+		return
+	}
+	onTime := !due.last.Before(now.Add(-job.Misfire.grace()))
+	missed := due.count
+	if onTime {
+		missed--
+	}
+	runs := job.Misfire.runs(onTime, missed)
+	if missed > 0 {
+		more := ""
+		if due.count > job.Misfire.limit() { // 达到统计上限，实际错过的次数可能更多
+			more = "以上"
+		}
+		logs.InfoLogger.Printf("作业错过执行%d次%s（最早：%v），处理方式：%s，补执行%d次，作业ID：%s",
+			missed, more, due.first, job.Misfire.Policy, runs, job.Id)
+	}
+	if onTime {
+		runs++
+	}
+	if runs == 0 {
+		return
+	}
+	// 首次按并发策略执行，其余的补执行排队，待作业空闲后依次执行，避免相互并行、跳过或终止
+	job2 := *job
+	s.dispatch(&job2)
+	if runs > 1 {
+		backlog := s.misfireBacklog[job.Id] + runs - 1
+		if backlog > job.Misfire.limit() {
+			backlog = job.Misfire.limit()
+		}
+		s.misfireBacklog[job.Id] = backlog
+		logs.InfoLogger.Printf("补执行排队，待上一次执行结束后依次执行，排队中%d次，作业ID：%s", backlog, job.Id)
+	}
+}
+
+// 作业空闲后启动下一次补执行，作业已被删除或关闭时放弃剩余的补执行
+func (s *Scheduler) launchBacklog(jobId string) {
+	backlog := s.misfireBacklog[jobId]
+	if backlog == 0 {
+		return
+	}
+	_, job := s.findJobById(jobId)
+	if job == nil || !job.Opened {
+		delete(s.misfireBacklog, jobId)
+		logs.InfoLogger.Printf("作业已删除或关闭，放弃补执行%d次，作业ID：%s", backlog, jobId)
+		return
+	}
+	if backlog == 1 {
+		delete(s.misfireBacklog, jobId)
+	} else {
+		s.misfireBacklog[jobId] = backlog - 1
+	}
+	logs.InfoLogger.Printf("启动补执行，剩余%d次，作业ID：%s", backlog-1, jobId)
+	job2 := *job
+	s.launch(&job2)
+}
+
+// 调度器启动或重载后，处理停机和调度器停止期间错过的执行
+// 从最近一次定时执行的开始时间与作业更新时间中较晚者开始计算，执行中、排队中或有待补执行的作业不处理
+func (s *Scheduler) recoverMisfires(now time.Time) {
+	for _, job := range s.jobs {
+		if !job.Opened || s.executingCount(job.Id) > 0 || s.queued[job.Id] != nil || s.misfireBacklog[job.Id] > 0 {
+			continue
+		}
+		last, err := s.store.LastScheduledStartTime(job.Id)
+		if err != nil {
+			logs.ErrorLogger.Printf("查询最近一次执行时间失败，作业ID：%s，%s", job.Id, err.Error())
+			continue
+		}
+		from := job.UpdateTime
+		if last.After(from) {
+			from = last
+		}
+		if from.IsZero() {
+			continue
+		}
+		s.fire(job, dueBetween(job.Cron, from, now, job.Misfire.limit()+1), now)
+	}
+}
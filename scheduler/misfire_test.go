@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMisfireRuns(t *testing.T) {
+	for _, c := range []struct {
+		policy MisfirePolicy
+		onTime bool
+		missed int
+		runs   int
+	}{
+		{MisfirePolicy{}, false, 3, 0},
+		{MisfirePolicy{Policy: OnceMisfirePolicy}, false, 3, 1},
+		{MisfirePolicy{Policy: OnceMisfirePolicy}, true, 3, 0},
+		{MisfirePolicy{Policy: AllMisfirePolicy}, false, 3, 3},
+		{MisfirePolicy{Policy: AllMisfirePolicy, Limit: 2}, true, 3, 2},
+		{MisfirePolicy{Policy: AllMisfirePolicy}, false, 100, defaultMisfireLimit},
+		{MisfirePolicy{Policy: AllMisfirePolicy}, true, 0, 0},
+	} {
+		if runs := c.policy.runs(c.onTime, c.missed); runs != c.runs {
+			t.Errorf("%+v，按时：%v，错过%d次：期望补执行%d次，实际%d次", c.policy, c.onTime, c.missed, c.runs, runs)
+		}
+	}
+}
+
+func TestDueBetween(t *testing.T) {
+	now := time.Date(2019, 6, 2, 6, 0, 0, 500, time.Local)
+	everySecond, _ := NewCron("* * * * * *")
+	hourly, _ := NewCron("0 0 * * * *")
+	for _, c := range []struct {
+		schedule    Schedule
+		from        time.Time
+		max         int
+		first, last time.Time
+		count       int
+	}{
+		// 停机30小时，超过Between的预览上限，最晚的执行时刻仍应为now之前的最近一次
+		{everySecond, now.Add(-30 * time.Hour), 11, now.Add(-30*time.Hour + time.Second).Truncate(time.Second),
+			now.Truncate(time.Second), 11},
+		{hourly, now.Add(-5 * time.Hour), 11, now.Add(-4 * time.Hour).Truncate(time.Hour), now.Truncate(time.Hour), 5},
+		{hourly, now.Add(-5 * time.Hour), 3, now.Add(-4 * time.Hour).Truncate(time.Hour), now.Truncate(time.Hour), 3},
+		{hourly, now.Truncate(time.Hour), 11, time.Time{}, time.Time{}, 0},
+		{&Every{Interval: 7 * time.Second}, now.Add(-30 * time.Hour), 11, now.Add(-30*time.Hour + 7*time.Second).Truncate(time.Second),
+			now.Add(-30 * time.Hour).Truncate(time.Second).Add(30 * time.Hour / (7 * time.Second) * 7 * time.Second), 11},
+	} {
+		due := dueBetween(c.schedule, c.from, now, c.max)
+		if !due.first.Equal(c.first) || !due.last.Equal(c.last) || due.count != c.count {
+			t.Errorf("%v (%v, %v]：期望%v ~ %v共%d次，实际%+v", c.schedule, c.from, now, c.first, c.last, c.count, due)
+		}
+	}
+
+	due := dueSince(hourly, now.Add(-2*time.Hour).Truncate(time.Hour), now, 11)
+	if due.count != 3 || !due.first.Equal(now.Add(-2*time.Hour).Truncate(time.Hour)) || !due.last.Equal(now.Truncate(time.Hour)) {
+		t.Errorf("定时器到达时应执行的执行时刻不符合预期：%+v", due)
+	}
+}
+
+func TestRecoverMisfires(t *testing.T) {
+	hourly, _ := NewCron("0 0 * * * *")
+	now := time.Date(2019, 6, 1, 12, 0, 30, 0, time.Local)
+	updated := now.Add(-5 * time.Hour) // 07:00:30，错过08:00至12:00共5次，12:00在宽限时间内
+
+	for policy, want := range map[string]int{
+		IgnoreMisfirePolicy: 1,
+		OnceMisfirePolicy:   1,
+		AllMisfirePolicy:    5,
+	} {
+		store := NewMemoryStore()
+		s := newScheduler(store, RealClock)
+		runs := make(chan struct{}, 10)
+		job := &Job{JobCore: JobCore{Id: "job-" + policy, Misfire: MisfirePolicy{Policy: policy}},
+			Opened: true, UpdateTime: updated, Cron: hourly}
+		job.Runner = func(ctx context.Context, args string) error {
+			runs <- struct{}{}
+			return nil
+		}
+		s.jobs = []*Job{job}
+
+		s.recoverMisfires(now)
+		for i := 0; i < want; i++ {
+			<-runs
+			if n := s.executingCount(job.Id); n != 1 {
+				t.Errorf("%s：补执行应依次进行，实际同时执行%d个", policy, n)
+			}
+			s.processFinished(<-s.finished)
+		}
+		if n := len(runs); n != 0 || len(s.executions) != 0 {
+			t.Errorf("%s：期望执行%d次，实际多执行%d次", policy, want, n)
+		}
+	}
+
+	// 最近一次定时执行晚于作业更新时间时，从最近一次执行开始计算
+	store := NewMemoryStore()
+	s := newScheduler(store, RealClock)
+	job := &Job{JobCore: JobCore{Id: "job-last", Misfire: MisfirePolicy{Policy: AllMisfirePolicy}},
+		Opened: true, UpdateTime: updated, Cron: hourly}
+	last := &JobResult{id: "result-last", jobId: job.Id, startTime: now.Add(-30 * time.Second)}
+	if err := store.InsertResult(last); err != nil {
+		t.Fatal(err)
+	}
+	s.jobs = []*Job{job}
+	s.recoverMisfires(now)
+	if n := len(s.executions); n != 0 {
+		t.Errorf("12:00已执行过，不应补执行，实际执行%d次", n)
+	}
+}
+
+func TestSchedulerMisfireAll(t *testing.T) {
+	for _, policy := range []string{SkipConcurrencyPolicy, QueueConcurrencyPolicy} {
+		s, clock := startTestScheduler(t)
+		ctx := context.Background()
+		if err := s.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		job, err := s.NewJob(ctx, &JobCore{Name: policy, CronRule: "0 0 * * * *", RunnerName: testRunnerName,
+			RunnerArgs: policy, ConcurrencyPolicy: policy, Misfire: MisfirePolicy{Policy: AllMisfirePolicy}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.OpenJob(ctx, job.Id); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.Stop(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		// 停止期间错过5次，最后一次在宽限时间内：按时执行1次，依次补执行4次，不应相互跳过
+		clock.Advance(5*time.Hour + 30*time.Second)
+		if err = s.Reload(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			waitRun(t, policy)
+		}
+		expectNoRun(t)
+		waitFor(t, "补执行结束", func() bool { return s.Executor().Running == 0 })
+		results, total, err := s.QueryResults(ctx, &ResultQuery{JobId: job.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.ExecuteState != SuccessJobExecuteState {
+				t.Errorf("%s：补执行不应被跳过，执行记录：%+v", policy, r)
+			}
+		}
+		if total != 5 {
+			t.Errorf("%s：期望执行记录5个，实际%d个", policy, total)
+		}
+		s.Close()
+	}
+}
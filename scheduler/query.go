@@ -0,0 +1,39 @@
+package scheduler
+
+import "context"
+
+// 按条件分页查询默认调度器的执行记录，参见Scheduler.QueryResults
+func QueryResults(ctx context.Context, query *ResultQuery) (results []*Result, total int, err error) {
+	return Default().QueryResults(ctx, query)
+}
+
+// 统计默认调度器中单个作业的执行情况，参见Scheduler.GetResultStats
+func GetResultStats(ctx context.Context, jobId string) (*ResultStats, error) {
+	return Default().GetResultStats(ctx, jobId)
+}
+
+// 按条件分页查询执行记录，结果按开始执行时间倒序，total为分页前的总数
+func (s *Scheduler) QueryResults(ctx context.Context, query *ResultQuery) (results []*Result, total int, err error) {
+	store, err := s.currentStore(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	results, total, err = store.QueryResults(query)
+	if err != nil {
+		return nil, 0, &DatabaseError{Op: "查询执行记录", Err: err}
+	}
+	return results, total, nil
+}
+
+// 统计单个作业的执行情况：各状态次数、最近成功/失败时间、平均耗时
+func (s *Scheduler) GetResultStats(ctx context.Context, jobId string) (*ResultStats, error) {
+	store, err := s.currentStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := store.GetResultStats(jobId)
+	if err != nil {
+		return nil, &DatabaseError{Op: "统计执行记录", Err: err}
+	}
+	return stats, nil
+}
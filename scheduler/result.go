@@ -1,17 +1,21 @@
 package scheduler
 
 import (
-	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
-	"github.com/xnffdd/gospider/database"
 	"time"
 )
 
+// 作业执行状态，对应MySQL的job_result表execute_state字段
 const (
-	successJobExecuteState = "SUCCESS"
-	failJobExecuteState    = "FAIL"
-	runningJobExecuteState = "RUNNING"
-	defaultJobExecuteState = runningJobExecuteState
+	SuccessJobExecuteState     = "SUCCESS"
+	FailJobExecuteState        = "FAIL"
+	RunningJobExecuteState     = "RUNNING"
+	TimeoutJobExecuteState     = "TIMEOUT"     // 超过作业的超时时间被中断
+	CancelledJobExecuteState   = "CANCELLED"   // 被终止执行指令中断
+	InterruptedJobExecuteState = "INTERRUPTED" // 调度器关闭时未结束而被中断
+	AbandonedJobExecuteState   = "ABANDONED"   // 执行所在的调度器实例失联（进程崩溃等），心跳超时后被回收
+	SkippedJobExecuteState     = "SKIPPED"     // 按并发策略跳过，未实际执行
+	defaultJobExecuteState     = RunningJobExecuteState
 )
 
 type JobResult struct {
@@ -33,11 +37,35 @@ type JobResult struct {
 	endTime      time.Time
 	executeState string
 	log          string
+	queueWait    time.Duration // 排队等待工作协程的时间，重试的尝试为0
+
+	// 重试信息
+	attempt  int    // 第几次尝试，从1开始
+	originId string // 首次尝试的执行记录ID，首次尝试为自身ID
+
+	// 触发信息
+	manual      bool   // 是否手动触发
+	triggeredBy string // 手动触发人
+
+	// 心跳信息
+	owner         string    // 执行所在的调度器实例ID
+	heartbeatTime time.Time // 最近一次心跳时间，执行开始时与开始时间相同
+
+	store ResultStore // 执行记录持久化
+	clock Clock       // 记录开始和结束时间
 }
 
-func NewJobResult(job *Job) *JobResult {
-	return &JobResult{
-		id:           uuid.New().String(),
+func NewJobResult(job *Job, store ResultStore) *JobResult {
+	return newJobResult(job, store, RealClock, "")
+}
+
+func newJobResult(job *Job, store ResultStore, clock Clock, owner string) *JobResult {
+	id := uuid.New().String()
+	result := &JobResult{
+		store:        store,
+		clock:        clock,
+		owner:        owner,
+		id:           id,
 		deleted:      false,
 		executeState: defaultJobExecuteState,
 
@@ -46,98 +74,229 @@ func NewJobResult(job *Job) *JobResult {
 		jobCronRule:   job.CronRule,
 		jobRunnerName: job.RunnerName,
 		jobRunnerArgs: job.RunnerArgs,
+
+		attempt:  1,
+		originId: id,
+	}
+	if job.trigger != nil {
+		result.manual = true
+		result.triggeredBy = job.trigger.By
+	}
+	return result
+}
+
+// 重试时的下一次尝试，作业信息与本次相同，关联到首次尝试
+func (result *JobResult) nextAttempt() *JobResult {
+	return &JobResult{
+		store:        result.store,
+		clock:        result.clock,
+		owner:        result.owner,
+		id:           uuid.New().String(),
+		deleted:      false,
+		executeState: defaultJobExecuteState,
+
+		jobId:         result.jobId,
+		jobName:       result.jobName,
+		jobCronRule:   result.jobCronRule,
+		jobRunnerName: result.jobRunnerName,
+		jobRunnerArgs: result.jobRunnerArgs,
+
+		attempt:  result.attempt + 1,
+		originId: result.originId,
+
+		manual:      result.manual,
+		triggeredBy: result.triggeredBy,
 	}
 }
 
 func (result *JobResult) SaveAtStart() error {
 	result.atStart()
-	return result.insert()
+	result.heartbeatTime = result.startTime
+	return result.store.InsertResult(result)
 }
 
 func (result *JobResult) SaveAtEnd(isSuccess bool, log string) error {
-	result.atEnd(isSuccess, log)
-	return result.update()
+	state := FailJobExecuteState
+	if isSuccess {
+		state = SuccessJobExecuteState
+	}
+	return result.SaveAtEndWithState(state, log)
+}
+
+// 以指定的执行状态结束，用于成功、失败以外的状态，例如TimeoutJobExecuteState
+func (result *JobResult) SaveAtEndWithState(state, log string) error {
+	result.atEnd(state, log)
+	return result.store.UpdateResult(result)
+}
+
+// 记录一次被跳过的执行，开始时间与结束时间相同
+func (result *JobResult) SaveSkipped(log string) error {
+	return result.saveUnstarted(SkippedJobExecuteState, log)
+}
+
+// 记录一次未实际执行的执行（被跳过、排队期间被终止等），开始时间与结束时间相同
+func (result *JobResult) saveUnstarted(state, log string) error {
+	result.atStart()
+	result.atEnd(state, log)
+	result.endTime = result.startTime
+	return result.store.InsertResult(result)
 }
 
 func (result *JobResult) atStart() {
-	t := time.Now()
+	t := result.clock.Now()
 
 	result.startTime = t
 	//result.endTime = mysql.NullTime{}
 	result.createTime = t
 	result.updateTime = t
 
-	result.executeState = runningJobExecuteState
+	result.executeState = RunningJobExecuteState
 }
 
-func (result *JobResult) atEnd(isSuccess bool, log string) {
-	t := time.Now()
+// 更新执行中记录的心跳时间，不修改result本身，可与执行并发调用
+func (result *JobResult) SaveHeartbeat() error {
+	return result.store.HeartbeatResult(result.id, result.clock.Now())
+}
+
+func (result *JobResult) atEnd(state, log string) {
+	t := result.clock.Now()
 
 	result.endTime = t
 	result.updateTime = t
 
 	result.log = log
 
-	if isSuccess {
-		result.executeState = successJobExecuteState
-	} else {
-		result.executeState = failJobExecuteState
-	}
+	result.executeState = state
 }
 
-func (result *JobResult) insert() error {
-	sql := "insert into job_result(id,deleted,ctime,utime,job_id,job_name,job_cron_rule,job_runner_name," +
-		"job_runner_args,start_time,end_time,execute_state,log) values(?,?,?,?,?,?,?,?,?,?,?,?,?)"
+// 对外的作业执行记录，字段含义与JobResult一致
+type Result struct {
+	Id            string
+	CreateTime    time.Time
+	UpdateTime    time.Time
+	JobId         string
+	JobName       string
+	JobCronRule   string
+	JobRunnerName string
+	JobRunnerArgs string
+	StartTime     time.Time
+	EndTime       time.Time // 执行尚未结束时为零值
+	ExecuteState  string
+	Log           string
+	Attempt       int           // 第几次尝试，从1开始
+	OriginId      string        // 首次尝试的执行记录ID，首次尝试为自身ID
+	Manual        bool          // 是否手动触发
+	TriggeredBy   string        // 手动触发人
+	Owner         string        // 执行所在的调度器实例ID
+	HeartbeatTime time.Time     // 最近一次心跳时间，没有心跳时为零值
+	QueueWait     time.Duration // 排队等待工作协程的时间
+}
 
-	stmt, err := database.MySQL.Prepare(sql)
-	if err != nil {
-		return err
-	}
-	res, err := stmt.Exec(result.id, result.deleted, result.createTime, result.updateTime,
-		result.jobId, result.jobName, result.jobCronRule, result.jobRunnerName, result.jobRunnerArgs,
-		result.startTime, mysql.NullTime{}, result.executeState, result.log)
-	if err != nil {
-		return err
+// 执行耗时，执行尚未结束时返回0
+func (r *Result) Duration() time.Duration {
+	if r.EndTime.IsZero() {
+		return 0
 	}
+	return r.EndTime.Sub(r.StartTime)
+}
 
-	_, err = res.RowsAffected()
-	if err != nil {
-		return err
+func (result *JobResult) export() *Result {
+	return &Result{
+		Id:            result.id,
+		CreateTime:    result.createTime,
+		UpdateTime:    result.updateTime,
+		JobId:         result.jobId,
+		JobName:       result.jobName,
+		JobCronRule:   result.jobCronRule,
+		JobRunnerName: result.jobRunnerName,
+		JobRunnerArgs: result.jobRunnerArgs,
+		StartTime:     result.startTime,
+		EndTime:       result.endTime,
+		ExecuteState:  result.executeState,
+		Log:           result.log,
+		Attempt:       result.attempt,
+		OriginId:      result.originId,
+		Manual:        result.manual,
+		TriggeredBy:   result.triggeredBy,
+		Owner:         result.owner,
+		HeartbeatTime: result.heartbeatTime,
+		QueueWait:     result.queueWait,
 	}
+}
 
-	err = stmt.Close()
-	if err != nil {
-		return err
-	}
+const (
+	defaultResultQueryLimit = 20
+	maxResultQueryLimit     = 1000
+)
 
-	return err
+// 执行记录查询条件，结果按开始执行时间倒序（最新的在前）
+type ResultQuery struct {
+	JobId    string    // 作业ID，为空表示全部作业
+	OriginId string    // 首次尝试的执行记录ID，用于查询同一次执行的全部尝试，为空表示不限
+	States   []string  // 执行状态，为空表示全部状态
+	From     time.Time // 开始执行时间下限（包含），零值表示不限
+	To       time.Time // 开始执行时间上限（不包含），零值表示不限
+	Offset   int       // 分页偏移
+	Limit    int       // 分页大小，<=0时取默认值20，最大1000
 }
 
-func (result *JobResult) update() error {
-	sql := "update job_result set deleted=?,ctime=?,utime=?,job_name=?,job_cron_rule=?,job_runner_name=?," +
-		"job_runner_args=?,start_time=?,end_time=?,execute_state=?,log=? where id=?"
-
-	stmt, err := database.MySQL.Prepare(sql)
-	if err != nil {
-		return err
+func (q *ResultQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultResultQueryLimit
 	}
-
-	res, err := stmt.Exec(result.deleted, result.createTime, result.updateTime,
-		result.jobName, result.jobCronRule, result.jobRunnerName, result.jobRunnerArgs,
-		result.startTime, result.endTime, result.executeState, result.log, result.id)
-	if err != nil {
-		return err
+	if q.Limit > maxResultQueryLimit {
+		return maxResultQueryLimit
 	}
+	return q.Limit
+}
 
-	_, err = res.RowsAffected()
-	if err != nil {
-		return err
+func (q *ResultQuery) offset() int {
+	if q.Offset < 0 {
+		return 0
 	}
+	return q.Offset
+}
 
-	err = stmt.Close()
-	if err != nil {
-		return err
+func (q *ResultQuery) matches(r *Result) bool {
+	if q.JobId != "" && r.JobId != q.JobId {
+		return false
+	}
+	if q.OriginId != "" && r.OriginId != q.OriginId {
+		return false
 	}
+	if len(q.States) > 0 {
+		matched := false
+		for _, state := range q.States {
+			if r.ExecuteState == state {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if !q.From.IsZero() && r.StartTime.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !r.StartTime.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// 单个作业的执行统计
+type ResultStats struct {
+	JobId           string
+	Total           int            // 执行次数
+	Counts          map[string]int // 执行状态 -> 次数
+	LastStartTime   time.Time      // 最近一次开始执行时间，没有时为零值
+	LastSuccessTime time.Time      // 最近一次成功执行的开始时间，没有时为零值
+	LastFailTime    time.Time      // 最近一次失败执行的开始时间，没有时为零值
+	AvgDuration     time.Duration  // 已结束执行的平均耗时
+}
 
-	return err
+// 失败次数
+func (stats *ResultStats) FailCount() int {
+	return stats.Counts[FailJobExecuteState]
 }
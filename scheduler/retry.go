@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 重试退避方式，对应MySQL的job表retry_backoff字段
+const (
+	FixedRetryBackoff       = "FIXED"       // 每次重试前等待相同的时间
+	ExponentialRetryBackoff = "EXPONENTIAL" // 每次重试前的等待时间翻倍
+)
+
+// 失败的错误类别，用于限定需要重试的失败，对应MySQL的job表retry_on字段
+const (
+	ErrorRetryClass     = "ERROR"     // 运行函数返回错误
+	TemporaryRetryClass = "TEMPORARY" // 运行函数返回的错误实现了Temporary() bool且返回true，例如net.Error，属于ERROR
+	TimeoutRetryClass   = "TIMEOUT"   // 超过作业的超时时间被中断
+	PanicRetryClass     = "PANIC"     // 运行函数宕机
+)
+
+// 重试策略，失败后由调度器按退避时间重新执行，每次尝试单独记录一条执行记录
+// 被终止执行指令中断的执行不会重试
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（含首次执行），<=1表示不重试，对应MySQL的job表retry_max_attempts字段
+	Backoff     string        // 退避方式，默认""等同于FIXED，对应MySQL的job表retry_backoff字段
+	Delay       time.Duration // 首次重试前的等待时间，对应MySQL的job表retry_delay字段（秒）
+	MaxDelay    time.Duration // 等待时间上限，0表示不限制，对应MySQL的job表retry_max_delay字段（秒）
+	Jitter      float64       // 随机抖动比例，取值[0,1]，实际等待时间在等待时间×(1±Jitter)之间，对应MySQL的job表retry_jitter字段
+	On          []string      // 需要重试的错误类别，为空表示全部失败都重试，对应MySQL的job表retry_on字段（逗号分隔）
+}
+
+// 校验重试策略
+func (p *RetryPolicy) Check() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("最大尝试次数非法，取值：%d", p.MaxAttempts)
+	}
+	switch p.Backoff {
+	case "", FixedRetryBackoff, ExponentialRetryBackoff:
+	default:
+		return fmt.Errorf("退避方式非法，取值：%s，可选：%s、%s", p.Backoff, FixedRetryBackoff, ExponentialRetryBackoff)
+	}
+	if p.Delay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("重试等待时间非法，等待时间：%v，上限：%v", p.Delay, p.MaxDelay)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("随机抖动比例非法，取值：%v，范围：[0,1]", p.Jitter)
+	}
+	for _, class := range p.On {
+		switch class {
+		case ErrorRetryClass, TemporaryRetryClass, TimeoutRetryClass, PanicRetryClass:
+		default:
+			return fmt.Errorf("错误类别非法，取值：%s，可选：%s、%s、%s、%s", class,
+				ErrorRetryClass, TemporaryRetryClass, TimeoutRetryClass, PanicRetryClass)
+		}
+	}
+	return nil
+}
+
+// 第attempt次尝试以class类别失败后是否重试，class为空表示未失败或被终止
+func (p *RetryPolicy) shouldRetry(attempt int, class string) bool {
+	if class == "" || attempt >= p.MaxAttempts {
+		return false
+	}
+	if len(p.On) == 0 {
+		return true
+	}
+	for _, on := range p.On {
+		if on == class || (on == ErrorRetryClass && class == TemporaryRetryClass) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// 第attempt次尝试失败后，下一次尝试前的等待时间
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Delay
+	if p.Backoff == ExponentialRetryBackoff {
+		for i := 1; i < attempt && d > 0; i++ {
+			if p.MaxDelay > 0 && d >= p.MaxDelay {
+				break
+			}
+			if d > math.MaxInt64/2 { // 防止溢出
+				break
+			}
+			d *= 2
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		jitterMu.Lock()
+		f := jitterRand.Float64()*2 - 1
+		jitterMu.Unlock()
+		d += time.Duration(float64(d) * p.Jitter * f)
+	}
+	return d
+}
+
+// 将重试策略的错误类别编码为retry_on字段
+func joinRetryClasses(classes []string) string {
+	return strings.Join(classes, ",")
+}
+
+// 将retry_on字段解码为错误类别
+func splitRetryClasses(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+type temporary interface {
+	Temporary() bool
+}
+
+// 单次尝试结束时的执行状态和错误类别，成功或被终止时错误类别为空
+func classify(ctx context.Context, err error) (state, class string) {
+	switch {
+	case err == nil:
+		return SuccessJobExecuteState, ""
+	case ctx.Err() == context.DeadlineExceeded:
+		return TimeoutJobExecuteState, TimeoutRetryClass
+	case ctx.Err() == context.Canceled && interrupted(ctx):
+		return InterruptedJobExecuteState, ""
+	case ctx.Err() == context.Canceled:
+		return CancelledJobExecuteState, ""
+	}
+	if t, ok := err.(temporary); ok && t.Temporary() {
+		return FailJobExecuteState, TemporaryRetryClass
+	}
+	return FailJobExecuteState, ErrorRetryClass
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	fixed := &RetryPolicy{MaxAttempts: 5, Delay: time.Second}
+	exponential := &RetryPolicy{MaxAttempts: 5, Backoff: ExponentialRetryBackoff, Delay: time.Second,
+		MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int][2]time.Duration{
+		1: {time.Second, time.Second},
+		2: {time.Second, 2 * time.Second},
+		3: {time.Second, 4 * time.Second},
+		4: {time.Second, 5 * time.Second},
+	} {
+		if d := fixed.delay(attempt); d != want[0] {
+			t.Errorf("FIXED第%d次：期望%v，实际%v", attempt, want[0], d)
+		}
+		if d := exponential.delay(attempt); d != want[1] {
+			t.Errorf("EXPONENTIAL第%d次：期望%v，实际%v", attempt, want[1], d)
+		}
+	}
+
+	jitter := &RetryPolicy{Delay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := jitter.delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("抖动后的等待时间超出范围：%v", d)
+		}
+	}
+
+	if err := (&RetryPolicy{Jitter: 2}).Check(); err == nil {
+		t.Error("随机抖动比例超出范围时应校验失败")
+	}
+	if err := (&RetryPolicy{On: []string{"NETWORK"}}).Check(); err == nil {
+		t.Error("未知的错误类别应校验失败")
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "连接被重置" }
+func (temporaryError) Temporary() bool { return true }
+
+func TestExecuteRetry(t *testing.T) {
+	store := NewMemoryStore()
+	s := newScheduler(store, RealClock)
+
+	var calls int
+	job := &Job{JobCore: JobCore{Id: "job-retry", Retry: RetryPolicy{MaxAttempts: 4, Delay: time.Millisecond,
+		On: []string{TemporaryRetryClass}}}}
+	job.Runner = func(ctx context.Context, args string) error {
+		calls++
+		if calls < 3 {
+			return temporaryError{}
+		}
+		return nil
+	}
+	first := NewJobResult(job, store)
+	s.execute(context.Background(), job, first)
+
+	results, total, err := store.QueryResults(&ResultQuery{OriginId: first.id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || results[0].Attempt != 3 || results[0].ExecuteState != SuccessJobExecuteState ||
+		results[2].ExecuteState != FailJobExecuteState {
+		t.Errorf("重试执行记录不符合预期：%+v", results)
+	}
+
+	// 不在重试范围内的错误类别不重试
+	calls = 0
+	job.Runner = func(ctx context.Context, args string) error {
+		calls++
+		return errors.New("参数错误")
+	}
+	s.execute(context.Background(), job, NewJobResult(job, store))
+	if calls != 1 {
+		t.Errorf("ERROR类别不应重试，实际执行%d次", calls)
+	}
+}
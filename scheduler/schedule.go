@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 调度时间计算器，Cron表达式和@every固定间隔均实现该接口
+type Schedule interface {
+	Next(time.Time) time.Time      // 返回晚于给定时间的下一个执行时刻，如果没有，则返回时间0
+	DescribeIn(lang string) string // 按语言描述调度规则，lang为EnglishLang时使用英文，否则使用中文
+}
+
+// 预定义的调度规则
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+const everyPrefix = "@every "
+
+// 固定间隔的调度，由@every指定，例如：@every 90s、@every 1h30m
+// 从给定时间开始计算，与时区无关，间隔向下取整到秒，最小1秒
+type Every struct {
+	Interval time.Duration
+}
+
+// 返回给定时间（向下取整到秒）加上间隔后的时刻
+func (e *Every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Second).Add(e.Interval)
+}
+
+func newEvery(rule string) (*Every, error) {
+	interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(rule, everyPrefix)))
+	if err != nil {
+		return nil, fmt.Errorf("解析间隔失败，表达式：\"%s\"，错误：%s", rule, err)
+	}
+	interval = interval.Truncate(time.Second)
+	if interval < time.Second {
+		return nil, fmt.Errorf("间隔不能小于1秒，表达式：\"%s\"", rule)
+	}
+	return &Every{Interval: interval}, nil
+}
+
+// 解析调度规则，支持5、6或7个字段的Cron表达式、预定义的@yearly、@monthly、@weekly、@daily、@hourly等，以及@every固定间隔
+func NewSchedule(rule string) (Schedule, error) {
+	return newSchedule(rule, "")
+}
+
+func newSchedule(rule, seed string) (Schedule, error) {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(rule, everyPrefix) {
+		return newEvery(rule)
+	}
+	return NewCronWithOptions(rule, CronOptions{Seed: seed})
+}
+
+// 按作业的调度规则和时区构建调度时间计算器，调度规则中的CRON_TZ=前缀优先于timeZone，@every不受时区影响
+// seed为H的哈希种子，通常为作业ID
+func NewJobSchedule(cronRule, timeZone, seed string) (Schedule, error) {
+	schedule, err := newSchedule(cronRule, seed)
+	if err != nil {
+		return nil, err
+	}
+	if cron, ok := schedule.(*Cron); ok && cron.Location == nil && timeZone != "" {
+		if cron.Location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("时区非法，时区：\"%s\"，%s", timeZone, err.Error())
+		}
+	}
+	return schedule, nil
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	from := time.Date(2019, 6, 5, 10, 20, 30, 500, time.UTC)
+	for rule, want := range map[string]time.Time{
+		"@yearly":                 time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"@annually":               time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"@monthly":                time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		"@weekly":                 time.Date(2019, 6, 9, 0, 0, 0, 0, time.UTC),
+		"@daily":                  time.Date(2019, 6, 6, 0, 0, 0, 0, time.UTC),
+		"@midnight":               time.Date(2019, 6, 6, 0, 0, 0, 0, time.UTC),
+		"@hourly":                 time.Date(2019, 6, 5, 11, 0, 0, 0, time.UTC),
+		"@DAILY":                  time.Date(2019, 6, 6, 0, 0, 0, 0, time.UTC),
+		"TZ=Asia/Shanghai @daily": time.Date(2019, 6, 5, 16, 0, 0, 0, time.UTC),
+		"@every 90s":              time.Date(2019, 6, 5, 10, 22, 0, 0, time.UTC),
+		"@every 1h30m":            time.Date(2019, 6, 5, 11, 50, 30, 0, time.UTC),
+		"@every 1500ms":           time.Date(2019, 6, 5, 10, 20, 31, 0, time.UTC),
+		"0 0 0 * * *":             time.Date(2019, 6, 6, 0, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := NewSchedule(rule)
+		if err != nil {
+			t.Errorf("%s：%s", rule, err.Error())
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(want) {
+			t.Errorf("%s：期望%v，实际%v", rule, want, next)
+		}
+	}
+
+	for _, rule := range []string{"@every", "@every 0s", "@every 500ms", "@every -1m", "@every x", "@fortnightly",
+		"@daily 0", "TZ=UTC @every 1m"} {
+		if _, err := NewSchedule(rule); err == nil {
+			t.Errorf("%s：应解析失败", rule)
+		}
+	}
+
+	schedule, err := NewJobSchedule("@every 1m", "Asia/Shanghai", "")
+	if err != nil || schedule.(*Every).Interval != time.Minute {
+		t.Errorf("@every不应受时区影响：%v，%v", schedule, err)
+	}
+}
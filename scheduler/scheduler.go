@@ -2,61 +2,192 @@ package scheduler
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/xnffdd/gospider/database"
 	"github.com/xnffdd/gospider/logs"
+	"os"
 	"sort"
+	"sync"
 	"time"
 )
 
-// 调度器
-type scheduler struct {
-	running           bool          // 调度器是否正在运行标志（使用mutex或者select互斥读写）
-	isRunningSnapshot chan bool     // 调度器是否正在运行快照
-	start             chan struct{} // 接收启动调度器指令
-	stop              chan struct{} // 传递停止调度器指令
-	reload            chan struct{} // 传递重启调度器指令
-	jobs              []*Job        // 调度中的作业集
-	new               chan *JobCore // 传递新建作业指令
-	update            chan *JobCore // 传递更新作业指令
-	open              chan string   // 传递开启作业指令
-	close             chan string   // 传递关闭作业指令
-	delete            chan string   // 传递删除作业指令
-	jobSnapshot       chan []*Job   // 调度中的作业集快照
+// 调度器，通过New创建，可同时存在多个互不影响的调度器
+type Scheduler struct {
+	running           bool                   // 调度器是否正在运行标志（使用mutex或者select互斥读写）
+	isRunningSnapshot chan bool              // 调度器是否正在运行快照
+	start             chan *command          // 接收启动调度器指令
+	stop              chan *command          // 传递停止调度器指令
+	reload            chan *command          // 传递重启调度器指令
+	quit              chan *command          // 传递关闭调度器指令
+	drain             chan *command          // 传递等待全部执行结束指令
+	interrupt         chan *command          // 传递中断全部执行指令
+	drainWaiters      []*command             // 等待全部执行结束的指令
+	closed            chan struct{}          // 调度器关闭后关闭此通道，listen协程随之退出
+	jobs              []*Job                 // 调度中的作业集
+	new               chan *command          // 传递新建作业指令
+	update            chan *command          // 传递更新作业指令
+	open              chan *command          // 传递开启作业指令
+	close             chan *command          // 传递关闭作业指令
+	delete            chan *command          // 传递删除作业指令
+	jobSnapshot       chan []*Job            // 调度中的作业集快照
+	useStore          chan *command          // 传递更换持久化指令
+	getStore          chan *command          // 传递获取持久化指令
+	store             Store                  // 作业及执行记录持久化
+	kill              chan *command          // 传递终止作业执行指令
+	trigger           chan *command          // 传递手动触发作业指令
+	finished          chan string            // 传递作业执行结束通知（执行记录ID）
+	executions        map[string]*execution  // 执行中的作业（包括排队等待工作协程的执行），执行记录ID -> 执行
+	saving            sync.WaitGroup         // 后台保存的执行记录，调度器关闭前等待全部保存结束
+	queued            map[string]*Job        // 排队等待上一次执行结束的作业，作业ID -> 作业快照
+	misfireBacklog    map[string]int         // 待依次补执行的次数（错过执行的ALL处理方式），作业ID -> 次数
+	pending           []*execution           // 排队等待工作协程的执行，按入队先后排列
+	workers           int                    // 占用工作协程的执行数量
+	runningRunners    map[string]int         // 各爬虫占用工作协程的执行数量
+	limits            WorkerLimits           // 工作协程限制
+	setLimits         chan *command          // 传递更新工作协程限制指令
+	executorSnapshot  chan *ExecutorSnapshot // 执行器快照
+	clock             Clock                  // 获取当前时间和创建定时器
+	runners           RunnerLookup           // 调度器可用的爬虫，创建后不再改变
+	instanceId        string                 // 调度器实例ID，记录到执行记录的owner字段
+	heartbeatInterval time.Duration          // 执行中更新心跳及回收失联执行记录的间隔
+	heartbeatTimeout  time.Duration          // 心跳超过该时间未更新的执行记录视为失联
 }
 
-// 单例模式，全局唯一调度器
-var gs *scheduler
+// 调度器选项
+type Options struct {
+	Store             Store         // 作业及执行记录持久化，默认nil表示启动时使用database.MySQL
+	Clock             Clock         // 时钟，默认nil表示使用系统时间RealClock
+	Runners           RunnerLookup  // 调度器可用的爬虫，默认nil表示使用全局注册的爬虫GlobalRunners
+	Workers           WorkerLimits  // 工作协程限制，默认不限制
+	InstanceId        string        // 调度器实例ID，记录到执行记录的owner字段，默认由主机名、进程号和随机串组成
+	HeartbeatInterval time.Duration // 执行中更新心跳及回收失联执行记录的间隔，默认30秒
+	HeartbeatTimeout  time.Duration // 心跳超过该时间未更新的执行中记录视为失联并标记为ABANDONED，默认且至少为心跳间隔的3倍
+}
+
+const defaultHeartbeatInterval = 30 * time.Second
+
+// 默认的调度器实例ID：主机名-进程号-随机串
+func defaultInstanceId() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// 创建调度器，调度器初始为停止状态，需调用Start或Reload启动，不再使用时调用Close或Shutdown释放
+func New(options Options) *Scheduler {
+	clock := options.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	s := newScheduler(options.Store, clock)
+	s.limits = options.Workers.copy()
+	if options.Runners != nil {
+		s.runners = options.Runners
+	}
+	if options.InstanceId != "" {
+		s.instanceId = options.InstanceId
+	}
+	if options.HeartbeatInterval > 0 {
+		s.heartbeatInterval = options.HeartbeatInterval
+	}
+	s.heartbeatTimeout = 3 * s.heartbeatInterval
+	if options.HeartbeatTimeout > s.heartbeatTimeout {
+		s.heartbeatTimeout = options.HeartbeatTimeout
+	}
+	go s.listen()
+	return s
+}
 
-func init() {
-	gs = &scheduler{
+// 调度器实例ID
+func (s *Scheduler) InstanceId() string {
+	return s.instanceId
+}
+
+// 调度器可用的爬虫名称，已排序
+func (s *Scheduler) RunnerNames() []string {
+	names := s.runners.GetRunnerNames()
+	sort.Strings(names)
+	return names
+}
+
+var (
+	defaultScheduler     *Scheduler
+	defaultSchedulerOnce sync.Once
+)
+
+// 默认调度器，包级别的指令函数均作用于默认调度器，首次调用时创建
+func Default() *Scheduler {
+	defaultSchedulerOnce.Do(func() {
+		defaultScheduler = New(Options{}) // 启动时若未指定持久化，则使用database.MySQL
+	})
+	return defaultScheduler
+}
+
+func newScheduler(store Store, clock Clock) *Scheduler {
+	return &Scheduler{
 		running:           false,
 		isRunningSnapshot: make(chan bool),
-		start:             make(chan struct{}),
-		stop:              make(chan struct{}),
-		reload:            make(chan struct{}),
+		start:             make(chan *command),
+		stop:              make(chan *command),
+		reload:            make(chan *command),
+		quit:              make(chan *command),
+		drain:             make(chan *command),
+		interrupt:         make(chan *command),
+		closed:            make(chan struct{}),
 		jobs:              nil,
-		new:               make(chan *JobCore),
-		update:            make(chan *JobCore),
-		open:              make(chan string),
-		close:             make(chan string),
-		delete:            make(chan string),
+		new:               make(chan *command),
+		update:            make(chan *command),
+		open:              make(chan *command),
+		close:             make(chan *command),
+		delete:            make(chan *command),
 		jobSnapshot:       make(chan []*Job),
+		useStore:          make(chan *command),
+		getStore:          make(chan *command),
+		store:             store,
+		kill:              make(chan *command),
+		trigger:           make(chan *command),
+		finished:          make(chan string),
+		executions:        make(map[string]*execution),
+		queued:            make(map[string]*Job),
+		misfireBacklog:    make(map[string]int),
+		runningRunners:    make(map[string]int),
+		setLimits:         make(chan *command),
+		executorSnapshot:  make(chan *ExecutorSnapshot),
+		clock:             clock,
+		runners:           GlobalRunners,
+		instanceId:        defaultInstanceId(),
+		heartbeatInterval: defaultHeartbeatInterval,
+		heartbeatTimeout:  3 * defaultHeartbeatInterval,
+	}
+}
+
+// 未指定持久化时使用database.MySQL，需事先调用database.InitMySQL
+func (s *Scheduler) ensureStore() error {
+	if s.store != nil {
+		return nil
+	}
+	if database.MySQL == nil {
+		return ErrStoreNotConfigured
 	}
-	go gs.listen()
+	s.store = NewMySQLStore(database.MySQL)
+	return nil
 }
 
-func (s *scheduler) loadJobs() {
-	jobs, err := LoadJobs()
+func (s *Scheduler) loadJobs() {
+	jobs, err := s.store.LoadJobs()
 	if err != nil {
 		logs.ErrorLogger.Printf("从数据库加载作业失败，%s", err.Error())
 	} else {
-		logs.InfoLogger.Printf("从数据库成功加载作业%d个", len(jobs))
-		s.jobs = jobs
+		s.jobs = buildJobs(jobs, s.runners)
+		logs.InfoLogger.Printf("从数据库成功加载作业%d个", len(s.jobs))
 	}
 }
 
-func (s *scheduler) calcJobsNextTime(after time.Time) {
+func (s *Scheduler) calcJobsNextTime(after time.Time) {
 	logs.InfoLogger.Println("计算全部作业的下一次执行时刻")
 	for _, job := range s.jobs {
 		if job.Opened {
@@ -67,35 +198,64 @@ func (s *scheduler) calcJobsNextTime(after time.Time) {
 	}
 }
 
-func (s *scheduler) sortJobsByNextTime() {
+func (s *Scheduler) sortJobsByNextTime() {
 	sort.Sort(JobsByTime(s.jobs))
 }
 
-func (s *scheduler) buildNextComingTimer() *time.Timer {
+func (s *Scheduler) buildNextComingTimer() Timer {
 	var duration time.Duration
 	if len(s.jobs) == 0 || s.jobs[0].Next.IsZero() {
 		duration = 24 * time.Hour // 休眠
 		logs.InfoLogger.Printf("更新定时器（到达时刻：%v，距离现在：%v）\n",
-			time.Now().Add(duration).Truncate(time.Second), duration)
+			s.clock.Now().Add(duration).Truncate(time.Second), duration)
 	} else {
 		next := s.jobs[0].Next
-		duration = s.jobs[0].Next.Sub(time.Now())
+		duration = s.jobs[0].Next.Sub(s.clock.Now())
 		logs.InfoLogger.Printf("更新定时器（到达时刻：%v，距离现在：%v）\n",
 			next, duration.Truncate(time.Second)+1*time.Second)
 	}
-	return time.NewTimer(duration)
+	return s.clock.NewTimer(duration)
+}
+
+// 执行作业直至成功、被终止或达到最大尝试次数，等待重试期间作业仍视为执行中
+func (s *Scheduler) execute(ctx context.Context, job *Job, result *JobResult) {
+	for {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if job.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+		}
+		class := s.runJobWithRecover(attemptCtx, job, result)
+		cancel()
+
+		if !job.Retry.shouldRetry(result.attempt, class) {
+			return
+		}
+		delay := job.Retry.delay(result.attempt)
+		logs.InfoLogger.Printf("作业执行失败（%s），%v后重试，作业ID：%s，执行记录ID：%s，第%d次尝试",
+			class, delay, job.Id, result.id, result.attempt)
+		timer := s.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			logs.InfoLogger.Printf("等待重试时被终止，作业ID：%s，执行记录ID：%s", job.Id, result.id)
+			return
+		}
+		result = result.nextAttempt()
+	}
 }
 
-func (s *scheduler) runJobWithRecover(job *Job) {
+// 执行一次作业并保存执行记录，返回失败的错误类别，成功或被终止时返回空
+func (s *Scheduler) runJobWithRecover(ctx context.Context, job *Job, result *JobResult) (class string) {
 	var bf bytes.Buffer
 	var err error
-	result := NewJobResult(job)
 	logs.InfoLogger.Printf("执行作业，作业ID：%s，执行记录ID：%s", job.Id, result.id)
 
 	defer func() {
 		if r := recover(); r != nil {
 			logs.ErrorLogger.Printf("作业宕机，作业ID：%s，执行记录ID：%s，%v", job.Id, result.id, r)
 			bf.WriteString(fmt.Sprintf("作业宕机，%v。", r))
+			class = PanicRetryClass
 			err = result.SaveAtEnd(false, bf.String())
 			if err != nil {
 				logs.ErrorLogger.Printf("保存执行结果到数据库时发生错误，作业ID：%s，执行记录ID：%s，%s",
@@ -111,25 +271,76 @@ func (s *scheduler) runJobWithRecover(job *Job) {
 		panic(err)
 	}
 
-	err = job.Runner(job.RunnerArgs)
+	stopHeartbeat := s.heartbeat(job, result)
+	defer stopHeartbeat()
+	err = job.Runner(ctx, job.RunnerArgs)
+	stopHeartbeat()
 
-	if err != nil {
-		bf.WriteString(fmt.Sprintf("任务执行返回错误：%v\n", err))
-	} else {
+	state, class := classify(ctx, err)
+	switch state {
+	case SuccessJobExecuteState:
 		bf.WriteString(fmt.Sprintf("任务执行成功\n"))
+	case TimeoutJobExecuteState:
+		bf.WriteString(fmt.Sprintf("任务执行超时（%v）：%v\n", job.Timeout, err))
+	case CancelledJobExecuteState:
+		bf.WriteString(fmt.Sprintf("任务被终止：%v\n", err))
+	case InterruptedJobExecuteState:
+		bf.WriteString(fmt.Sprintf("调度器关闭，任务被中断：%v\n", err))
+	default:
+		bf.WriteString(fmt.Sprintf("任务执行返回错误：%v\n", err))
 	}
 
-	err = result.SaveAtEnd(err == nil, bf.String())
+	err = result.SaveAtEndWithState(state, bf.String())
 	if err != nil {
 		panic(err)
 	}
+	return class
+}
+
+// 执行期间定期更新执行记录的心跳时间，返回停止函数，重复调用停止函数无影响
+func (s *Scheduler) heartbeat(job *Job, result *JobResult) func() {
+	done := make(chan struct{})
+	timer := s.clock.NewTimer(s.heartbeatInterval)
+	go func() {
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C():
+				if err := result.SaveHeartbeat(); err != nil {
+					logs.ErrorLogger.Printf("更新执行心跳失败，作业ID：%s，执行记录ID：%s，%s", job.Id, result.id, err.Error())
+				}
+				timer.Reset(s.heartbeatInterval)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
 }
 
-func (s *scheduler) listen() {
+// 回收心跳超时的执行中记录（所在的调度器实例已崩溃或失联），标记为ABANDONED
+func (s *Scheduler) reapAbandoned(now time.Time) {
+	log := fmt.Sprintf("调度器实例失联，心跳超过%v未更新，执行被回收", s.heartbeatTimeout)
+	affect, err := s.store.AbandonResults(now.Add(-s.heartbeatTimeout), now, log)
+	if err != nil {
+		logs.ErrorLogger.Printf("回收失联的执行记录失败，%s", err.Error())
+	} else if affect > 0 {
+		logs.InfoLogger.Printf("回收失联的执行记录%d个", affect)
+	}
+}
+
+func (s *Scheduler) listen() {
+	reaper := s.clock.NewTimer(s.heartbeatInterval) // 定期回收失联的执行记录
 	for {
 		if s.running {
+			now := s.clock.Now()
+			s.reapAbandoned(now)
 			s.loadJobs()
-			s.calcJobsNextTime(time.Now())
+			s.calcJobsNextTime(now)
+			s.recoverMisfires(now)
 		} else {
 			s.jobs = nil // 空转
 		}
@@ -141,30 +352,79 @@ func (s *scheduler) listen() {
 			for {
 				select {
 
-				case <-s.start:
+				case cmd := <-s.start:
 					logs.InfoLogger.Printf("启动调度器指令到达")
-					if !s.running {
+					if err := s.ensureStore(); err != nil {
+						logs.ErrorLogger.Printf("调度器启动失败，%s", err.Error())
+						cmd.done(nil, err)
+					} else if !s.running {
 						logs.InfoLogger.Printf("调度器启动")
 						s.running = true
+						cmd.done(nil, nil)
 						break SchedulerStateChanged
 					} else {
 						logs.ErrorLogger.Printf("重复启动调度器")
+						cmd.done(nil, ErrSchedulerAlreadyRunning)
 					}
 
-				case <-s.stop:
+				case cmd := <-s.stop:
 					logs.InfoLogger.Printf("停止调度器指令到达")
 					if s.running {
 						logs.InfoLogger.Printf("调度器停止")
 						s.running = false
+						cmd.done(nil, nil)
 						break SchedulerStateChanged
 					} else {
 						logs.ErrorLogger.Printf("重复停止调度器")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
-				case <-s.reload:
+				case cmd := <-s.reload:
 					logs.InfoLogger.Printf("重载调度器指令到达")
-					s.running = true
-					break SchedulerStateChanged
+					if err := s.ensureStore(); err != nil {
+						logs.ErrorLogger.Printf("调度器重载失败，%s", err.Error())
+						cmd.done(nil, err)
+					} else {
+						s.running = true
+						cmd.done(nil, nil)
+						break SchedulerStateChanged
+					}
+
+				case cmd := <-s.quit:
+					logs.InfoLogger.Printf("关闭调度器指令到达")
+					timer.Stop()
+					reaper.Stop()
+					s.running = false
+					s.jobs = nil
+					logs.InfoLogger.Printf("调度器关闭，中断未结束的执行%d个", len(s.executions))
+					s.processInterruptCMD()
+					s.markInterrupted()
+					s.saving.Wait()
+					close(s.closed)
+					for _, waiter := range s.drainWaiters {
+						waiter.done(nil, ErrSchedulerClosed)
+					}
+					s.drainWaiters = nil
+					cmd.done(nil, nil)
+					return
+
+				case cmd := <-s.drain:
+					logs.InfoLogger.Printf("等待执行结束指令到达，执行中%d个，排队中%d个", len(s.executions), len(s.queued))
+					s.queued = make(map[string]*Job) // 不再启动排队中的执行和补执行
+					s.misfireBacklog = make(map[string]int)
+					for len(s.pending) > 0 {
+						s.dropPending(s.pending[0], InterruptedJobExecuteState, "调度器关闭，放弃排队等待工作协程的执行")
+					}
+					if len(s.executions) == 0 {
+						cmd.done(nil, nil)
+					} else {
+						s.drainWaiters = append(s.drainWaiters, cmd)
+					}
+
+				case cmd := <-s.interrupt:
+					logs.InfoLogger.Printf("中断全部执行指令到达，执行中%d个", len(s.executions))
+					s.processInterruptCMD()
+					cmd.done(nil, nil)
 
 				case <-s.jobSnapshot:
 					logs.InfoLogger.Printf("作业快照指令到达")
@@ -175,95 +435,161 @@ func (s *scheduler) listen() {
 					}
 					s.jobSnapshot <- jobs
 
+				case cmd := <-s.useStore:
+					logs.InfoLogger.Printf("更换持久化指令到达")
+					if !s.running {
+						s.store = cmd.store
+						cmd.done(nil, nil)
+					} else {
+						logs.ErrorLogger.Printf("调度器运行中，不允许更换持久化")
+						cmd.done(nil, ErrSchedulerRunning)
+					}
+
+				case cmd := <-s.getStore:
+					err := s.ensureStore()
+					cmd.reply <- &commandResult{store: s.store, err: err}
+
+				case id := <-s.finished:
+					s.processFinished(id)
+
+				case cmd := <-s.setLimits:
+					logs.InfoLogger.Printf("更新工作协程限制指令到达")
+					s.processSetLimitsCMD(*cmd.limits)
+					cmd.done(nil, nil)
+
+				case <-s.executorSnapshot:
+					logs.InfoLogger.Printf("执行器快照指令到达")
+					s.executorSnapshot <- s.snapshotExecutor()
+
+				case cmd := <-s.kill:
+					logs.InfoLogger.Printf("终止作业执行指令到达")
+					delete(s.misfireBacklog, cmd.jobId) // 终止时放弃剩余的补执行
+					killed := s.processKillJobCMD(cmd.jobId)
+					s.notifyDrained()
+					_, job := s.findJobById(cmd.jobId)
+					if killed == 0 {
+						logs.ErrorLogger.Printf("终止作业执行失败，作业ID：%s，%s", cmd.jobId, ErrJobNotExecuting.Error())
+						cmd.done(job, ErrJobNotExecuting)
+					} else {
+						logs.InfoLogger.Printf("终止作业执行成功，作业ID：%s，执行%d个", cmd.jobId, killed)
+						cmd.done(job, nil)
+					}
+
+				case cmd := <-s.trigger:
+					logs.InfoLogger.Printf("手动触发作业指令到达")
+					resultId, err := s.processTriggerJobCMD(cmd.jobId, cmd.trigger)
+					if err != nil {
+						logs.ErrorLogger.Printf("手动触发作业失败，作业ID：%s，%s", cmd.jobId, err.Error())
+					} else {
+						logs.InfoLogger.Printf("手动触发作业成功，作业ID：%s，执行记录ID：%s", cmd.jobId, resultId)
+					}
+					cmd.reply <- &commandResult{resultId: resultId, err: err}
+
+				case <-reaper.C():
+					if s.running {
+						s.reapAbandoned(s.clock.Now())
+					}
+					reaper.Reset(s.heartbeatInterval)
+
 				case <-s.isRunningSnapshot:
 					logs.InfoLogger.Printf("运行状态快照指令到达")
 					s.isRunningSnapshot <- s.running
 
-				case now := <-timer.C:
+				case now := <-timer.C():
 					logs.InfoLogger.Printf("定时器到达")
 					for _, job := range s.jobs {
 						if job.Next.After(now) || job.Next.IsZero() {
 							break
 						}
+						due := dueSince(job.Cron, job.Next, now, job.Misfire.limit()+1)
 						job.Next = job.Cron.Next(now)
-						job2 := *job
-						go s.runJobWithRecover(&job2)
+						s.fire(job, due, now)
 					}
 					break JobsChanged
 
-				case jobCore := <-s.new:
+				case cmd := <-s.new:
 					logs.InfoLogger.Printf("新建作业指令到达")
 					if s.running {
-						err := s.processNewJobCMD(jobCore)
+						job, err := s.processNewJobCMD(cmd.jobCore)
+						cmd.done(job, err)
 						if err != nil {
 							logs.ErrorLogger.Printf("新建作业失败，%s", err.Error())
 						} else {
-							logs.InfoLogger.Printf("新建作业成功")
+							logs.InfoLogger.Printf("新建作业成功，作业ID：%s", job.Id)
 							timer.Stop()
 							break JobsChanged
 						}
 					} else {
 						logs.ErrorLogger.Printf("调度器未启动")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
-				case id := <-s.delete:
+				case cmd := <-s.delete:
 					logs.InfoLogger.Printf("删除作业指令到达")
 					if s.running {
-						err := s.processDeleteJobCMD(id)
+						job, err := s.processDeleteJobCMD(cmd.jobId)
+						cmd.done(job, err)
 						if err != nil {
-							logs.ErrorLogger.Printf("删除作业失败，作业ID：%s，%s", id, err.Error())
+							logs.ErrorLogger.Printf("删除作业失败，作业ID：%s，%s", cmd.jobId, err.Error())
 						} else {
-							logs.InfoLogger.Printf("删除作业成功，作业ID：%s", id)
+							logs.InfoLogger.Printf("删除作业成功，作业ID：%s", cmd.jobId)
 							timer.Stop()
 							break JobsChanged
 						}
 					} else {
 						logs.ErrorLogger.Printf("调度器未启动")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
-				case jobCore := <-s.update:
+				case cmd := <-s.update:
 					logs.InfoLogger.Printf("更新作业指令到达")
 					if s.running {
-						err := s.processUpdateJobCMD(jobCore)
+						job, err := s.processUpdateJobCMD(cmd.jobCore)
+						cmd.done(job, err)
 						if err != nil {
-							logs.ErrorLogger.Printf("更新作业失败，作业ID：%s，%s", jobCore.Id, err.Error())
+							logs.ErrorLogger.Printf("更新作业失败，作业ID：%s，%s", cmd.jobCore.Id, err.Error())
 						} else {
-							logs.InfoLogger.Printf("更新作业成功，作业ID：%s", jobCore.Id)
+							logs.InfoLogger.Printf("更新作业成功，作业ID：%s", cmd.jobCore.Id)
 							timer.Stop()
 							break JobsChanged
 						}
 					} else {
 						logs.ErrorLogger.Printf("调度器未启动")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
-				case id := <-s.open:
+				case cmd := <-s.open:
 					logs.InfoLogger.Printf("开启作业指令到达")
 					if s.running {
-						err := s.processOpenJobCMD(id)
+						job, err := s.processOpenJobCMD(cmd.jobId)
+						cmd.done(job, err)
 						if err != nil {
-							logs.ErrorLogger.Printf("开启作业失败，作业ID：%s，%s", id, err.Error())
+							logs.ErrorLogger.Printf("开启作业失败，作业ID：%s，%s", cmd.jobId, err.Error())
 						} else {
-							logs.InfoLogger.Printf("开启作业成功，作业ID：%s", id)
+							logs.InfoLogger.Printf("开启作业成功，作业ID：%s", cmd.jobId)
 							timer.Stop()
 							break JobsChanged
 						}
 					} else {
 						logs.ErrorLogger.Printf("调度器未启动")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
-				case id := <-s.close:
+				case cmd := <-s.close:
 					logs.InfoLogger.Printf("关闭作业指令到达")
 					if s.running {
-						err := s.processCloseJobCMD(id)
+						job, err := s.processCloseJobCMD(cmd.jobId)
+						cmd.done(job, err)
 						if err != nil {
-							logs.ErrorLogger.Printf("关闭作业失败，作业ID：%s，%s", id, err.Error())
+							logs.ErrorLogger.Printf("关闭作业失败，作业ID：%s，%s", cmd.jobId, err.Error())
 						} else {
-							logs.InfoLogger.Printf("关闭作业成功，作业ID：%s", id)
+							logs.InfoLogger.Printf("关闭作业成功，作业ID：%s", cmd.jobId)
 							timer.Stop()
 							break JobsChanged
 						}
 					} else {
 						logs.ErrorLogger.Printf("调度器未启动")
+						cmd.done(nil, ErrSchedulerNotRunning)
 					}
 
 				}
@@ -272,43 +598,122 @@ func (s *scheduler) listen() {
 	}
 }
 
-func (s *scheduler) processNewJobCMD(jobCore *JobCore) error {
+func (s *Scheduler) processNewJobCMD(jobCore *JobCore) (*Job, error) {
 	var err error
 	job := &Job{}
 	job.JobCore = *jobCore
 	job.Id = uuid.New().String()
-	err = job.build()
+	err = job.build(s.runners)
 	if err != nil {
-		return fmt.Errorf("构建作业失败，%s", err.Error())
+		return nil, &JobBuildError{Err: err}
 	} else { // Built
-		_, err = InsertJob(job)
+		_, err = s.store.InsertJob(job, s.clock.Now())
 		if err != nil {
-			return fmt.Errorf("执行数据库插入作业失败，%s", err.Error())
+			return nil, &DatabaseError{Op: "插入作业", Err: err}
 		} else { // Inserted into database
-			s.jobs = append(s.jobs, job)         // Append to scheduling jobs
-			job.Next = job.Cron.Next(time.Now()) // Calculate next execution time
-			return nil
+			s.jobs = append(s.jobs, job) // Append to scheduling jobs
+			if job.Opened {
+				job.Next = job.Cron.Next(s.clock.Now()) // Calculate next execution time
+			}
+			return job, nil
 		}
 	}
 }
 
-func (s *scheduler) processDeleteJobCMD(id string) error {
+func (s *Scheduler) processDeleteJobCMD(id string) (*Job, error) {
 	idx, job := s.findJobById(id)
 	if job != nil { // Found
 		var err error
-		_, err = DeleteJob(job)
+		_, err = s.store.DeleteJob(job, s.clock.Now())
 		if err != nil {
-			return fmt.Errorf("执行数据库删除作业失败，%s", err.Error())
+			return nil, &DatabaseError{Op: "删除作业", Err: err}
 		} else { // Deleted from database
 			s.jobs = append(s.jobs[:idx], s.jobs[idx+1:]...) // Remove from scheduling jobs
-			return nil
+			job.Next = time.Time{}
+			return job, nil
+		}
+	} else {
+		return nil, ErrJobNotFound
+	}
+}
+
+// 执行结束，释放工作协程并启动排队中的执行
+func (s *Scheduler) processFinished(id string) {
+	if e, ok := s.executions[id]; ok {
+		s.release(e)
+		s.launchQueued(e.jobId)
+		s.startPending()
+	}
+	s.notifyDrained()
+}
+
+// 中断作业的全部执行，返回被中断的执行数量
+// 排队等待工作协程的执行直接记录为CANCELLED
+func (s *Scheduler) processKillJobCMD(jobId string) int {
+	killed := 0
+	for _, e := range s.executions {
+		if e.jobId != jobId {
+			continue
+		}
+		if e.started {
+			e.cancel()
+		} else {
+			s.dropPending(e, CancelledJobExecuteState, "排队等待工作协程时被终止")
+		}
+		killed++
+	}
+	return killed
+}
+
+// 中断全部执行，被中断或放弃排队的执行记录状态为INTERRUPTED
+func (s *Scheduler) processInterruptCMD() {
+	for _, e := range s.executions {
+		if e.started {
+			e.interrupt()
+		} else {
+			s.dropPending(e, InterruptedJobExecuteState, "调度器关闭，放弃排队等待工作协程的执行")
 		}
+	}
+}
+
+// 调度器关闭时将仍未结束的执行记录标记为INTERRUPTED，避免关闭数据库后执行记录一直保持RUNNING状态
+// 执行随后自行结束时若数据库仍可用，以其实际结束状态为准
+func (s *Scheduler) markInterrupted() {
+	var ids []string
+	for id, e := range s.executions {
+		if e.started {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	affect, err := s.store.InterruptResults(ids, s.clock.Now(), "调度器关闭，执行未在关闭前结束，记录为中断")
+	if err != nil {
+		logs.ErrorLogger.Printf("标记未结束的执行记录失败，%s", err.Error())
 	} else {
-		return fmt.Errorf("作业不存在")
+		logs.InfoLogger.Printf("标记未结束的执行记录%d个", affect)
 	}
 }
 
-func (s *scheduler) findJobById(jobId string) (int, *Job) {
+// 立即按作业的并发策略执行作业，不影响作业的下一次执行时刻
+func (s *Scheduler) processTriggerJobCMD(jobId string, trigger *Trigger) (string, error) {
+	if !s.running {
+		return "", ErrSchedulerNotRunning
+	}
+	_, job := s.findJobById(jobId)
+	if job == nil {
+		return "", ErrJobNotFound
+	}
+	job2 := *job
+	job2.trigger = trigger
+	if trigger.RunnerArgs != nil {
+		job2.RunnerArgs = *trigger.RunnerArgs
+	}
+	return s.dispatch(&job2)
+}
+
+func (s *Scheduler) findJobById(jobId string) (int, *Job) {
 	for idx, job := range s.jobs {
 		if job.Id == jobId {
 			return idx, job
@@ -317,70 +722,72 @@ func (s *scheduler) findJobById(jobId string) (int, *Job) {
 	return -1, nil
 }
 
-func (s *scheduler) processUpdateJobCMD(jobCore *JobCore) error {
+func (s *Scheduler) processUpdateJobCMD(jobCore *JobCore) (*Job, error) {
 	idx, job := s.findJobById(jobCore.Id)
 	if idx >= 0 { // Found
 		var err error
 		job2 := *job
 		job2.JobCore = *jobCore
-		err = job2.build()
+		err = job2.build(s.runners)
 		if err != nil { // Built
-			return fmt.Errorf("构建作业失败，%s", err.Error())
+			return nil, &JobBuildError{Err: err}
 		} else {
-			_, err = UpdateJob(&job2) // Updated to database
+			_, err = s.store.UpdateJob(&job2, s.clock.Now()) // Updated to database
 			if err != nil {
-				return fmt.Errorf("执行数据库更新作业失败，%s", err.Error())
+				return nil, &DatabaseError{Op: "更新作业", Err: err}
 			} else {
-				s.jobs[idx] = &job2                    // Replace job in scheduling jobs
-				job2.Next = job2.Cron.Next(time.Now()) // Calculate next execution time
-				return nil
+				s.jobs[idx] = &job2 // Replace job in scheduling jobs
+				if job2.Opened {
+					job2.Next = job2.Cron.Next(s.clock.Now()) // Calculate next execution time
+				}
+				return &job2, nil
 			}
 		}
 	} else {
-		return fmt.Errorf("作业不存在")
+		return nil, ErrJobNotFound
 	}
 }
 
-func (s *scheduler) processOpenJobCMD(id string) error {
+func (s *Scheduler) processOpenJobCMD(id string) (*Job, error) {
 	_, job := s.findJobById(id)
 	if job != nil { // Found
 		if job.Opened { // Already opened
-			return fmt.Errorf("重复开启作业")
+			return nil, ErrJobAlreadyOpened
 		} else {
 			var err error
 			job.Opened = true
-			_, err = UpdateJob(job)
+			_, err = s.store.UpdateJob(job, s.clock.Now())
 			if err != nil {
 				job.Opened = false // Reset
-				return fmt.Errorf("执行数据库更新作业失败，%s", err.Error())
+				return nil, &DatabaseError{Op: "更新作业", Err: err}
 			} else { // Updated to database
-				job.Next = job.Cron.Next(time.Now()) // Calculate next execution time
-				return nil
+				job.Next = job.Cron.Next(s.clock.Now()) // Calculate next execution time
+				return job, nil
 			}
 		}
 	} else {
-		return fmt.Errorf("作业不存在")
+		return nil, ErrJobNotFound
 	}
 }
 
-func (s *scheduler) processCloseJobCMD(id string) error {
+func (s *Scheduler) processCloseJobCMD(id string) (*Job, error) {
 	_, job := s.findJobById(id)
 	if job != nil { // Found
 		if !job.Opened { // Already closed
-			return fmt.Errorf("重复关闭作业")
+			return nil, ErrJobAlreadyClosed
 		} else {
 			var err error
 			job.Opened = false
-			_, err = UpdateJob(job)
+			_, err = s.store.UpdateJob(job, s.clock.Now())
 			if err != nil {
 				job.Opened = true // Reset
-				return fmt.Errorf("执行数据库更新作业失败，%s", err.Error())
+				return nil, &DatabaseError{Op: "更新作业", Err: err}
 			} else { // Updated to database
 				job.Next = time.Time{} // Reset next execution time to zero(means not scheduled)
-				return nil
+				return job, nil
 			}
 		}
 	} else {
-		return fmt.Errorf("作业不存在")
+		return nil, ErrJobNotFound
 	}
 }
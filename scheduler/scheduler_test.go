@@ -0,0 +1,506 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const (
+	testRunnerName      = "SchedulerTest"
+	testBlockRunnerName = "SchedulerBlockTest" // 执行后阻塞，直至testRelease可读或被终止
+)
+
+var (
+	testRuns    = make(chan string, 100) // 测试爬虫每次执行时传出参数
+	testRelease = make(chan struct{})
+)
+
+// 测试爬虫只通过调度器选项提供，不注册到全局
+var testRunners = RunnerMap{
+	testRunnerName: func(ctx context.Context, args string) error {
+		testRuns <- args
+		return nil
+	},
+	testBlockRunnerName: func(ctx context.Context, args string) error {
+		testRuns <- args
+		select {
+		case <-testRelease:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	},
+}
+
+// 使用内存持久化和手动推进的时钟创建调度器，调度器初始为停止状态
+func startTestScheduler(t *testing.T) (*Scheduler, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Now().Truncate(time.Hour))
+	return New(Options{Store: NewMemoryStore(), Clock: clock, Runners: testRunners}), clock
+}
+
+func execTestCMD(t *testing.T, s *Scheduler, ch chan *command, cmd *command) (*Job, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := s.sendCMD(ctx, ch, cmd)
+	if err == context.DeadlineExceeded {
+		t.Fatal("等待指令执行超时")
+	}
+	return job, err
+}
+
+// 等待测试爬虫以参数args执行
+func waitRun(t *testing.T, args string) {
+	t.Helper()
+	select {
+	case a := <-testRuns:
+		if a != args {
+			t.Fatalf("期望执行参数为%s的作业，实际%s", args, a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待参数为%s的作业执行超时", args)
+	}
+}
+
+// 确认一段时间内没有作业执行
+func expectNoRun(t *testing.T) {
+	t.Helper()
+	select {
+	case a := <-testRuns:
+		t.Fatalf("不应执行作业，实际执行了参数为%s的作业", a)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSchedulerStartStopReload(t *testing.T) {
+	s, _ := startTestScheduler(t)
+
+	if s.IsRunning() {
+		t.Fatal("调度器初始应为停止状态")
+	}
+	if _, err := execTestCMD(t, s, s.stop, &command{}); err != ErrSchedulerNotRunning {
+		t.Errorf("停止未启动的调度器应返回ErrSchedulerNotRunning，实际：%v", err)
+	}
+	if _, err := execTestCMD(t, s, s.new, &command{jobCore: &JobCore{}}); err != ErrSchedulerNotRunning {
+		t.Errorf("调度器未启动时新建作业应返回ErrSchedulerNotRunning，实际：%v", err)
+	}
+
+	if _, err := execTestCMD(t, s, s.start, &command{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execTestCMD(t, s, s.start, &command{}); err != ErrSchedulerAlreadyRunning {
+		t.Errorf("重复启动应返回ErrSchedulerAlreadyRunning，实际：%v", err)
+	}
+	if !s.IsRunning() {
+		t.Error("启动后应为运行状态")
+	}
+	if _, err := execTestCMD(t, s, s.useStore, &command{store: NewMemoryStore()}); err != ErrSchedulerRunning {
+		t.Errorf("运行中更换持久化应返回ErrSchedulerRunning，实际：%v", err)
+	}
+
+	job, err := execTestCMD(t, s, s.new, &command{jobCore: &JobCore{Name: "a", CronRule: "@daily",
+		RunnerName: testRunnerName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execTestCMD(t, s, s.stop, &command{}); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsRunning() || len(s.Jobs()) != 0 {
+		t.Error("停止后应不再调度作业")
+	}
+
+	// 重载时从持久化加载作业，未启动时同时启动
+	if _, err := execTestCMD(t, s, s.reload, &command{}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := s.Jobs()
+	if !s.IsRunning() || len(jobs) != 1 || jobs[0].Id != job.Id {
+		t.Errorf("重载后应从持久化加载作业：%v", jobs)
+	}
+}
+
+func TestSchedulerJobCommands(t *testing.T) {
+	s, clock := startTestScheduler(t)
+	if _, err := execTestCMD(t, s, s.start, &command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := execTestCMD(t, s, s.new, &command{jobCore: &JobCore{CronRule: "0 0 0 1 13 *", RunnerName: testRunnerName}})
+	if _, ok := err.(*JobBuildError); !ok {
+		t.Errorf("调度规则非法时应返回*JobBuildError，实际：%v", err)
+	}
+
+	job, err := execTestCMD(t, s, s.new, &command{jobCore: &JobCore{Name: "a", CronRule: "0 0 * * * *",
+		RunnerName: testRunnerName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Id == "" || job.Opened || !job.Next.IsZero() || !job.CreateTime.Equal(clock.Now()) {
+		t.Errorf("新建的作业不符合预期（创建时间应取自调度器的时钟）：%+v", job)
+	}
+
+	core := job.JobCore
+	core.CronRule = "0 30 * * * *"
+	job, err = s.UpdateJob(context.Background(), &core)
+	if err != nil || job.CronRule != "0 30 * * * *" {
+		t.Errorf("更新作业失败：%v，%+v", err, job)
+	}
+	core.Id = "not-exist"
+	if _, err := s.UpdateJob(context.Background(), &core); err != ErrJobNotFound {
+		t.Errorf("更新不存在的作业应返回ErrJobNotFound，实际：%v", err)
+	}
+
+	job, err = execTestCMD(t, s, s.open, &command{jobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := clock.Now().Add(30 * time.Minute); !job.Opened || !job.Next.Equal(want) {
+		t.Errorf("开启后下一次执行时刻期望%v，实际%v", want, job.Next)
+	}
+	if _, err := execTestCMD(t, s, s.open, &command{jobId: job.Id}); err != ErrJobAlreadyOpened {
+		t.Errorf("重复开启应返回ErrJobAlreadyOpened，实际：%v", err)
+	}
+
+	job, err = execTestCMD(t, s, s.close, &command{jobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Opened || !job.Next.IsZero() {
+		t.Errorf("关闭后不应再调度：%+v", job)
+	}
+	if _, err := execTestCMD(t, s, s.close, &command{jobId: job.Id}); err != ErrJobAlreadyClosed {
+		t.Errorf("重复关闭应返回ErrJobAlreadyClosed，实际：%v", err)
+	}
+
+	if _, err := execTestCMD(t, s, s.delete, &command{jobId: job.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execTestCMD(t, s, s.delete, &command{jobId: job.Id}); err != ErrJobNotFound {
+		t.Errorf("重复删除应返回ErrJobNotFound，实际：%v", err)
+	}
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Errorf("删除后不应再调度：%v", jobs)
+	}
+	if jobs, err := s.store.LoadJobs(); err != nil || len(jobs) != 0 {
+		t.Errorf("删除后持久化中不应存在作业：%v %v", jobs, err)
+	}
+}
+
+func TestSchedulerTimer(t *testing.T) {
+	s, clock := startTestScheduler(t)
+	if _, err := execTestCMD(t, s, s.start, &command{}); err != nil {
+		t.Fatal(err)
+	}
+	start := clock.Now()
+
+	job, err := execTestCMD(t, s, s.new, &command{jobCore: &JobCore{Name: "timer", CronRule: "0 * * * * *",
+		RunnerName: testRunnerName, RunnerArgs: "timer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = execTestCMD(t, s, s.open, &command{jobId: job.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if !job.Next.Equal(start.Add(time.Minute)) {
+		t.Fatalf("下一次执行时刻期望%v，实际%v", start.Add(time.Minute), job.Next)
+	}
+
+	expectNoRun(t)
+	clock.Advance(time.Minute)
+	waitRun(t, "timer")
+	clock.Advance(time.Minute)
+	waitRun(t, "timer")
+
+	// 执行记录的时间来自注入的时钟
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		results, _, err := s.store.QueryResults(&ResultQuery{JobId: job.Id, States: []string{SuccessJobExecuteState}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 2 {
+			if !results[0].StartTime.Equal(start.Add(2*time.Minute)) ||
+				!results[1].StartTime.Equal(start.Add(time.Minute)) {
+				t.Errorf("执行记录的开始时间不符合预期：%v、%v", results[0].StartTime, results[1].StartTime)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待执行记录超时：%v", results)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := execTestCMD(t, s, s.close, &command{jobId: job.Id}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	expectNoRun(t)
+
+	if _, err := execTestCMD(t, s, s.open, &command{jobId: job.Id}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	waitRun(t, "timer")
+
+	if _, err := execTestCMD(t, s, s.stop, &command{}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	expectNoRun(t)
+}
+
+func TestSchedulerLifecycle(t *testing.T) {
+	a, _ := startTestScheduler(t)
+	b, _ := startTestScheduler(t)
+	ctx := context.Background()
+
+	if err := a.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !a.IsRunning() || b.IsRunning() {
+		t.Fatal("调度器之间应互不影响")
+	}
+	job, err := a.NewJob(ctx, &JobCore{Name: "block", CronRule: "@daily", RunnerName: testBlockRunnerName,
+		RunnerArgs: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenJob(ctx, job.Id); err != ErrSchedulerNotRunning {
+		t.Errorf("未启动的调度器应返回ErrSchedulerNotRunning，实际：%v", err)
+	}
+	if _, err := a.TriggerJob(ctx, job.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "block")
+
+	// Shutdown等待执行结束后关闭调度器
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- a.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("执行结束前Shutdown不应返回：%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if a.IsRunning() {
+		t.Error("Shutdown开始后应停止调度")
+	}
+	testRelease <- struct{}{}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(ctx); err != ErrSchedulerClosed {
+		t.Errorf("关闭后启动应返回ErrSchedulerClosed，实际：%v", err)
+	}
+	if a.IsRunning() || a.Jobs() != nil {
+		t.Error("关闭后不应再调度作业")
+	}
+	if err := a.Close(); err != ErrSchedulerClosed {
+		t.Errorf("重复关闭应返回ErrSchedulerClosed，实际：%v", err)
+	}
+
+	// 超时后中断未结束的执行，执行记录状态为INTERRUPTED
+	if err := b.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, err = b.NewJob(ctx, &JobCore{Name: "block", CronRule: "@daily", RunnerName: testBlockRunnerName,
+		RunnerArgs: "timeout", ConcurrencyPolicy: QueueConcurrencyPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultId, err := b.TriggerJob(ctx, job.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "timeout")
+	if id, err := b.TriggerJob(ctx, job.Id, nil); err != nil || id != "" {
+		t.Fatalf("第二次触发应排队等待：%s %v", id, err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := b.Shutdown(timeout); err != context.DeadlineExceeded {
+		t.Errorf("等待超时应返回context.DeadlineExceeded，实际：%v", err)
+	}
+	if _, err := b.NewJob(ctx, &JobCore{}); err != ErrSchedulerClosed {
+		t.Errorf("关闭后新建作业应返回ErrSchedulerClosed，实际：%v", err)
+	}
+	expectNoRun(t) // 排队中的执行被放弃
+	results, _, err := b.store.QueryResults(&ResultQuery{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id != resultId || results[0].ExecuteState != InterruptedJobExecuteState {
+		t.Errorf("未结束的执行应记录为INTERRUPTED：%+v", results)
+	}
+}
+
+func TestSchedulerCloseMarksInterrupted(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	defer close(release)
+	runners := RunnerMap{"Stubborn": func(ctx context.Context, args string) error {
+		testRuns <- args
+		<-release // 不响应中断
+		return nil
+	}}
+	s := New(Options{Store: store, Runners: runners})
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.NewJob(ctx, &JobCore{Name: "stubborn", CronRule: "@daily", RunnerName: "Stubborn",
+		RunnerArgs: "stubborn", ConcurrencyPolicy: SkipConcurrencyPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultId, err := s.TriggerJob(ctx, job.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "stubborn")
+	skippedId, err := s.TriggerJob(ctx, job.Id, nil)
+	if _, ok := err.(*JobSkippedError); !ok {
+		t.Fatalf("第二次触发应被跳过：%v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭返回时执行记录均已写入，未退出的执行记录为INTERRUPTED
+	results, _, err := store.QueryResults(&ResultQuery{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]string)
+	for _, r := range results {
+		states[r.Id] = r.ExecuteState
+	}
+	if len(states) != 2 || states[resultId] != InterruptedJobExecuteState || states[skippedId] != SkippedJobExecuteState {
+		t.Errorf("关闭后的执行记录不符合预期：%v", states)
+	}
+}
+
+// 等待条件成立，最多5秒
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulerReapAbandoned(t *testing.T) {
+	store := NewMemoryStore()
+	clock := NewFakeClock(time.Now().Truncate(time.Hour))
+	s := New(Options{Store: store, Clock: clock, InstanceId: "node-1", HeartbeatInterval: time.Minute,
+		Runners: testRunners})
+	defer s.Close()
+	ctx := context.Background()
+
+	insertOrphan := func(id string) {
+		t.Helper()
+		orphan := &JobResult{id: id, jobId: "crashed", owner: "node-0", startTime: clock.Now(),
+			heartbeatTime: clock.Now(), executeState: RunningJobExecuteState}
+		if err := store.InsertResult(orphan); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := func(id string) string {
+		results, _, err := store.QueryResults(&ResultQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Id == id {
+				return r.ExecuteState
+			}
+		}
+		return ""
+	}
+
+	// 启动时回收
+	insertOrphan("orphan-1")
+	clock.Advance(4 * time.Minute)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if st := state("orphan-1"); st != AbandonedJobExecuteState {
+		t.Errorf("启动时应回收失联的执行记录，实际状态：%s", st)
+	}
+
+	// 执行中定期更新心跳
+	job, err := s.NewJob(ctx, &JobCore{Name: "block", CronRule: "@daily", RunnerName: testBlockRunnerName,
+		RunnerArgs: "heartbeat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultId, err := s.TriggerJob(ctx, job.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRun(t, "heartbeat")
+	start := clock.Now()
+	clock.Advance(time.Minute)
+	waitFor(t, "心跳更新", func() bool {
+		results, _, err := store.QueryResults(&ResultQuery{JobId: job.Id})
+		return err == nil && len(results) == 1 && results[0].Id == resultId && results[0].Owner == "node-1" &&
+			results[0].HeartbeatTime.Equal(start.Add(time.Minute))
+	})
+	testRelease <- struct{}{}
+	waitFor(t, "执行结束", func() bool { return state(resultId) == SuccessJobExecuteState })
+
+	// 运行中定期回收
+	insertOrphan("orphan-2")
+	clock.Advance(4 * time.Minute)
+	waitFor(t, "定期回收", func() bool { return state("orphan-2") == AbandonedJobExecuteState })
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	a := clock.NewTimer(2 * time.Second)
+	b := clock.NewTimer(time.Second)
+	c := clock.NewTimer(3 * time.Second)
+	if !c.Stop() || clock.Timers() != 2 {
+		t.Fatalf("停止定时器后应剩余2个，实际%d个", clock.Timers())
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case when := <-b.C():
+		if !when.Equal(start.Add(time.Second)) {
+			t.Errorf("定时器收到的时间应为到期时刻，实际%v", when)
+		}
+	default:
+		t.Error("到期的定时器应被触发")
+	}
+	select {
+	case <-a.C():
+		t.Error("未到期的定时器不应被触发")
+	default:
+	}
+
+	a.Reset(0)
+	select {
+	case <-a.C():
+	default:
+		t.Error("间隔为0的定时器应立即触发")
+	}
+	clock.Advance(time.Hour)
+	select {
+	case <-c.C():
+		t.Error("已停止的定时器不应被触发")
+	default:
+	}
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import "time"
+
+// 作业持久化接口，调度器通过它加载和保存作业
+type JobStore interface {
+	LoadJobs() ([]*Job, error) // 加载全部未删除的作业，返回的作业尚未构建（Cron、Runner为nil）
+	// 以下写操作的时间由调用方传入（调度器取自其时钟），与执行记录的时间保持一致
+	InsertJob(job *Job, now time.Time) (affect int64, err error) // 插入作业，成功后以now回写CreateTime、UpdateTime
+	UpdateJob(job *Job, now time.Time) (affect int64, err error) // 更新作业，成功后以now回写UpdateTime
+	DeleteJob(job *Job, now time.Time) (affect int64, err error) // 软删除作业，成功后回写Deleted，并以now回写UpdateTime
+}
+
+// 作业执行结果持久化接口
+type ResultStore interface {
+	InsertResult(result *JobResult) error // 作业开始执行时插入执行记录
+	UpdateResult(result *JobResult) error // 作业执行结束时更新执行记录
+
+	QueryResults(query *ResultQuery) (results []*Result, total int, err error) // 按条件分页查询执行记录，total为分页前的总数
+	GetResultStats(jobId string) (*ResultStats, error)                         // 统计单个作业的执行情况
+	LastScheduledStartTime(jobId string) (time.Time, error)                    // 最近一次定时（非手动触发）执行的开始时间，没有时返回零值
+
+	HeartbeatResult(id string, t time.Time) error // 更新执行中（RUNNING）记录的心跳时间，记录已结束时不更新
+	// 将心跳时间（没有心跳时取开始时间）早于staleBefore的执行中记录标记为ABANDONED，结束时间取最近一次心跳时间，返回回收的记录数
+	AbandonResults(staleBefore, now time.Time, log string) (int64, error)
+	// 调度器关闭时将首次尝试ID属于originIds的执行中记录标记为INTERRUPTED，返回标记的记录数
+	InterruptResults(originIds []string, now time.Time, log string) (int64, error)
+}
+
+// 调度器所需的全部持久化接口
+type Store interface {
+	JobStore
+	ResultStore
+}
@@ -0,0 +1,244 @@
+package scheduler
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errDuplicateKey = errors.New("主键重复")
+
+// 内存持久化，进程退出后数据丢失，适用于测试和嵌入式使用
+type memoryStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job       // 作业ID -> 作业副本
+	results map[string]*JobResult // 执行记录ID -> 执行记录副本
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		jobs:    make(map[string]*Job),
+		results: make(map[string]*JobResult),
+	}
+}
+
+// 仅保存可持久化的字段，与SQL实现保持一致
+func storedJobOf(job *Job) *Job {
+	return &Job{
+		JobCore:    job.JobCore,
+		CreateTime: job.CreateTime,
+		UpdateTime: job.UpdateTime,
+		Deleted:    job.Deleted,
+		Opened:     job.Opened,
+	}
+}
+
+func (store *memoryStore) LoadJobs() ([]*Job, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var jobs []*Job
+	for _, job := range store.jobs {
+		if !job.Deleted {
+			jobs = append(jobs, storedJobOf(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreateTime.Before(jobs[j].CreateTime) })
+	return jobs, nil
+}
+
+func (store *memoryStore) InsertJob(job *Job, now time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.jobs[job.Id]; ok {
+		return 0, errDuplicateKey
+	}
+	job.CreateTime = now
+	job.UpdateTime = now
+	store.jobs[job.Id] = storedJobOf(job)
+	return 1, nil
+}
+
+func (store *memoryStore) UpdateJob(job *Job, now time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stored, ok := store.jobs[job.Id]
+	if !ok {
+		return 0, nil
+	}
+	job.UpdateTime = now
+	stored.JobCore = job.JobCore
+	stored.Opened = job.Opened
+	stored.UpdateTime = job.UpdateTime
+	return 1, nil
+}
+
+func (store *memoryStore) DeleteJob(job *Job, now time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stored, ok := store.jobs[job.Id]
+	if !ok {
+		return 0, nil
+	}
+	job.Deleted = true
+	job.UpdateTime = now
+	stored.Deleted = job.Deleted
+	stored.UpdateTime = job.UpdateTime
+	return 1, nil
+}
+
+func (store *memoryStore) InsertResult(result *JobResult) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.results[result.id]; ok {
+		return errDuplicateKey
+	}
+	result2 := *result
+	store.results[result.id] = &result2
+	return nil
+}
+
+func (store *memoryStore) UpdateResult(result *JobResult) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.results[result.id]; ok {
+		result2 := *result
+		store.results[result.id] = &result2
+	}
+	return nil
+}
+
+func (store *memoryStore) QueryResults(query *ResultQuery) ([]*Result, int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var matched []*Result
+	for _, result := range store.results {
+		r := result.export()
+		if !result.deleted && query.matches(r) {
+			matched = append(matched, r)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].StartTime.After(matched[j].StartTime) })
+
+	total := len(matched)
+	start := query.offset()
+	if start > total {
+		start = total
+	}
+	end := start + query.limit()
+	if end > total {
+		end = total
+	}
+	return matched[start:end], total, nil
+}
+
+func (store *memoryStore) GetResultStats(jobId string) (*ResultStats, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stats := &ResultStats{JobId: jobId, Counts: make(map[string]int)}
+	var ended int
+	var sum time.Duration
+	for _, result := range store.results {
+		if result.deleted || result.jobId != jobId {
+			continue
+		}
+		stats.Total++
+		stats.Counts[result.executeState]++
+		if result.startTime.After(stats.LastStartTime) {
+			stats.LastStartTime = result.startTime
+		}
+		switch result.executeState {
+		case SuccessJobExecuteState:
+			if result.startTime.After(stats.LastSuccessTime) {
+				stats.LastSuccessTime = result.startTime
+			}
+		case FailJobExecuteState:
+			if result.startTime.After(stats.LastFailTime) {
+				stats.LastFailTime = result.startTime
+			}
+		}
+		if !result.endTime.IsZero() {
+			ended++
+			sum += result.endTime.Sub(result.startTime)
+		}
+	}
+	if ended > 0 {
+		stats.AvgDuration = sum / time.Duration(ended)
+	}
+	return stats, nil
+}
+
+func (store *memoryStore) LastScheduledStartTime(jobId string) (time.Time, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var last time.Time
+	for _, result := range store.results {
+		if !result.deleted && !result.manual && result.jobId == jobId && result.startTime.After(last) {
+			last = result.startTime
+		}
+	}
+	return last, nil
+}
+
+func (store *memoryStore) HeartbeatResult(id string, t time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if result, ok := store.results[id]; ok && result.executeState == RunningJobExecuteState {
+		result.heartbeatTime = t
+	}
+	return nil
+}
+
+func (store *memoryStore) AbandonResults(staleBefore, now time.Time, log string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var affect int64
+	for _, result := range store.results {
+		last := result.heartbeatTime
+		if last.IsZero() {
+			last = result.startTime
+		}
+		if result.executeState != RunningJobExecuteState || !last.Before(staleBefore) {
+			continue
+		}
+		result.executeState = AbandonedJobExecuteState
+		result.endTime = last
+		result.updateTime = now
+		result.log = log
+		affect++
+	}
+	return affect, nil
+}
+
+func (store *memoryStore) InterruptResults(originIds []string, now time.Time, log string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	origins := make(map[string]bool, len(originIds))
+	for _, id := range originIds {
+		origins[id] = true
+	}
+	var affect int64
+	for _, result := range store.results {
+		if result.executeState != RunningJobExecuteState || !origins[result.originId] {
+			continue
+		}
+		result.executeState = InterruptedJobExecuteState
+		result.endTime = now
+		result.updateTime = now
+		result.log = log
+		affect++
+	}
+	return affect, nil
+}
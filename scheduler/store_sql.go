@@ -0,0 +1,406 @@
+package scheduler
+
+import (
+	"database/sql"
+	"github.com/xnffdd/gospider/database"
+	"strings"
+	"time"
+)
+
+// 基于database/sql的持久化实现，MySQL与SQLite共用同一套SQL，个别方言差异按dialect区分
+type sqlStore struct {
+	db      *sql.DB
+	dialect string // database.MySQLDialect或database.SQLiteDialect
+}
+
+// MySQL持久化，db通常为database.MySQL
+func NewMySQLStore(db *sql.DB) Store {
+	return &sqlStore{db: db, dialect: database.MySQLDialect}
+}
+
+// SQLite持久化，适用于单节点部署，db通常由database.OpenSQLite打开
+func NewSQLiteStore(db *sql.DB) Store {
+	return &sqlStore{db: db, dialect: database.SQLiteDialect}
+}
+
+func (store *sqlStore) LoadJobs() ([]*Job, error) {
+	var jobs []*Job
+
+	sql := "select id,ctime,utime,deleted,name,cron_rule,opened,runner_name,runner_args,timeout,concurrency_policy," +
+		policyColumns + " from job where deleted=?"
+
+	rows, err := store.db.Query(sql, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job := &Job{}
+		var timeout, retryDelay, retryMaxDelay, misfireGrace int64
+		var retryOn string
+		if err = rows.Scan(&job.Id, &job.CreateTime, &job.UpdateTime, &job.Deleted, &job.Name, &job.CronRule,
+			&job.Opened, &job.RunnerName, &job.RunnerArgs, &timeout, &job.ConcurrencyPolicy,
+			&job.TimeZone, &job.Retry.MaxAttempts, &job.Retry.Backoff, &retryDelay, &retryMaxDelay, &job.Retry.Jitter,
+			&retryOn, &job.Misfire.Policy, &job.Misfire.Limit, &misfireGrace); err != nil {
+			return nil, err
+		}
+		job.Timeout = time.Duration(timeout) * time.Second
+		job.Retry.Delay = time.Duration(retryDelay) * time.Second
+		job.Retry.MaxDelay = time.Duration(retryMaxDelay) * time.Second
+		job.Retry.On = splitRetryClasses(retryOn)
+		job.Misfire.Grace = time.Duration(misfireGrace) * time.Second
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (store *sqlStore) DeleteJob(job *Job, now time.Time) (affect int64, err error) {
+	sql := "update job set utime=?,deleted=? where id=?"
+
+	stmt, err := store.db.Prepare(sql)
+	if err != nil {
+		return
+	}
+
+	res, err := stmt.Exec(now, true, job.Id)
+	if err != nil {
+		return
+	}
+
+	affect, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return
+	}
+
+	job.Deleted = true
+	job.UpdateTime = now
+
+	return
+}
+
+const policyColumns = "time_zone,retry_max_attempts,retry_backoff,retry_delay,retry_max_delay,retry_jitter,retry_on," +
+	"misfire_policy,misfire_limit,misfire_grace"
+
+// 时区、重试策略、错过执行的处理策略对应policyColumns的字段值
+func policyValues(job *Job) []interface{} {
+	p, m := &job.Retry, &job.Misfire
+	return []interface{}{job.TimeZone, p.MaxAttempts, p.Backoff, int64(p.Delay / time.Second), int64(p.MaxDelay / time.Second),
+		p.Jitter, joinRetryClasses(p.On), misfirePolicyOrDefault(m.Policy), m.Limit, int64(m.Grace / time.Second)}
+}
+
+func (store *sqlStore) InsertJob(job *Job, now time.Time) (affect int64, err error) {
+	sql := "insert into job(id,ctime,utime,deleted,name,cron_rule,opened,runner_name,runner_args,timeout," +
+		"concurrency_policy," + policyColumns + ") values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+
+	stmt, err := store.db.Prepare(sql)
+
+	if err != nil {
+		return
+	}
+
+	args := []interface{}{job.Id, now, now, job.Deleted, job.Name, job.CronRule, job.Opened, job.RunnerName,
+		job.RunnerArgs, int64(job.Timeout / time.Second), concurrencyPolicyOrDefault(job.ConcurrencyPolicy)}
+	res, err := stmt.Exec(append(args, policyValues(job)...)...)
+	if err != nil {
+		return
+	}
+
+	affect, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return
+	}
+
+	job.CreateTime = now
+	job.UpdateTime = now
+
+	return
+}
+
+func (store *sqlStore) UpdateJob(job *Job, now time.Time) (affect int64, err error) {
+	sql := "update job set utime=?,name=?,cron_rule=?,opened=?,runner_name=?,runner_args=?,timeout=?," +
+		"concurrency_policy=?,time_zone=?,retry_max_attempts=?,retry_backoff=?,retry_delay=?,retry_max_delay=?,retry_jitter=?," +
+		"retry_on=?,misfire_policy=?,misfire_limit=?,misfire_grace=? where id=?"
+
+	stmt, err := store.db.Prepare(sql)
+	if err != nil {
+		return
+	}
+
+	args := []interface{}{now, job.Name, job.CronRule, job.Opened, job.RunnerName, job.RunnerArgs,
+		int64(job.Timeout / time.Second), concurrencyPolicyOrDefault(job.ConcurrencyPolicy)}
+	args = append(args, policyValues(job)...)
+	res, err := stmt.Exec(append(args, job.Id)...)
+	if err != nil {
+		return
+	}
+
+	affect, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return
+	}
+
+	job.UpdateTime = now
+
+	return
+}
+
+func (store *sqlStore) InsertResult(result *JobResult) error {
+	sql := "insert into job_result(id,deleted,ctime,utime,job_id,job_name,job_cron_rule,job_runner_name," +
+		"job_runner_args,start_time,end_time,execute_state,log,attempt,origin_id,manual,triggered_by,owner,heartbeat_time," +
+		"queue_wait) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+
+	stmt, err := store.db.Prepare(sql)
+	if err != nil {
+		return err
+	}
+	var endTime, heartbeatTime interface{} // 执行尚未结束时end_time为null，被跳过的执行没有心跳
+	if !result.endTime.IsZero() {
+		endTime = result.endTime
+	}
+	if !result.heartbeatTime.IsZero() {
+		heartbeatTime = result.heartbeatTime
+	}
+	res, err := stmt.Exec(result.id, result.deleted, result.createTime, result.updateTime,
+		result.jobId, result.jobName, result.jobCronRule, result.jobRunnerName, result.jobRunnerArgs,
+		result.startTime, endTime, result.executeState, result.log, result.attempt, result.originId,
+		result.manual, result.triggeredBy, result.owner, heartbeatTime, int64(result.queueWait/time.Millisecond))
+	if err != nil {
+		return err
+	}
+
+	_, err = res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func (store *sqlStore) UpdateResult(result *JobResult) error {
+	sql := "update job_result set deleted=?,ctime=?,utime=?,job_name=?,job_cron_rule=?,job_runner_name=?," +
+		"job_runner_args=?,start_time=?,end_time=?,execute_state=?,log=? where id=?"
+
+	stmt, err := store.db.Prepare(sql)
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(result.deleted, result.createTime, result.updateTime,
+		result.jobName, result.jobCronRule, result.jobRunnerName, result.jobRunnerArgs,
+		result.startTime, result.endTime, result.executeState, result.log, result.id)
+	if err != nil {
+		return err
+	}
+
+	_, err = res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+const resultColumns = "id,ctime,utime,job_id,job_name,job_cron_rule,job_runner_name,job_runner_args," +
+	"start_time,end_time,execute_state,log,attempt,origin_id,manual,triggered_by,owner,heartbeat_time,queue_wait"
+
+func scanResult(rows *sql.Rows) (*Result, error) {
+	r := &Result{}
+	var endTime, heartbeatTime *time.Time // 执行尚未结束或没有心跳时为NULL
+	var queueWait int64                   // 毫秒
+	err := rows.Scan(&r.Id, &r.CreateTime, &r.UpdateTime, &r.JobId, &r.JobName, &r.JobCronRule,
+		&r.JobRunnerName, &r.JobRunnerArgs, &r.StartTime, &endTime, &r.ExecuteState, &r.Log, &r.Attempt, &r.OriginId,
+		&r.Manual, &r.TriggeredBy, &r.Owner, &heartbeatTime, &queueWait)
+	if err != nil {
+		return nil, err
+	}
+	r.QueueWait = time.Duration(queueWait) * time.Millisecond
+	if endTime != nil {
+		r.EndTime = *endTime
+	}
+	if heartbeatTime != nil {
+		r.HeartbeatTime = *heartbeatTime
+	}
+	return r, nil
+}
+
+func (store *sqlStore) QueryResults(query *ResultQuery) ([]*Result, int, error) {
+	where := []string{"deleted=?"}
+	args := []interface{}{false}
+	if query.JobId != "" {
+		where = append(where, "job_id=?")
+		args = append(args, query.JobId)
+	}
+	if query.OriginId != "" {
+		where = append(where, "origin_id=?")
+		args = append(args, query.OriginId)
+	}
+	if len(query.States) > 0 {
+		where = append(where, "execute_state in (?"+strings.Repeat(",?", len(query.States)-1)+")")
+		for _, state := range query.States {
+			args = append(args, state)
+		}
+	}
+	if !query.From.IsZero() {
+		where = append(where, "start_time>=?")
+		args = append(args, query.From)
+	}
+	if !query.To.IsZero() {
+		where = append(where, "start_time<?")
+		args = append(args, query.To)
+	}
+	cond := " where " + strings.Join(where, " and ")
+
+	var total int
+	if err := store.db.QueryRow("select count(*) from job_result"+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sql := "select " + resultColumns + " from job_result" + cond + " order by start_time desc limit ? offset ?"
+	rows, err := store.db.Query(sql, append(args, query.limit(), query.offset())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*Result
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, r)
+	}
+	return results, total, rows.Err()
+}
+
+func (store *sqlStore) GetResultStats(jobId string) (*ResultStats, error) {
+	stats := &ResultStats{JobId: jobId, Counts: make(map[string]int)}
+
+	rows, err := store.db.Query("select execute_state,count(*) from job_result where job_id=? and deleted=? "+
+		"group by execute_state", jobId, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var count int
+		if err = rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		stats.Counts[state] = count
+		stats.Total += count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for state, last := range map[string]*time.Time{
+		"":                     &stats.LastStartTime,
+		SuccessJobExecuteState: &stats.LastSuccessTime,
+		FailJobExecuteState:    &stats.LastFailTime,
+	} {
+		if err = store.lastStartTime(jobId, state, last); err != nil {
+			return nil, err
+		}
+	}
+
+	// 平均耗时，单位微秒
+	avg := "avg(timestampdiff(microsecond,start_time,end_time))"
+	if store.dialect == database.SQLiteDialect {
+		avg = "avg((julianday(end_time)-julianday(start_time))*86400000000)"
+	}
+	var micros sql.NullFloat64
+	err = store.db.QueryRow("select "+avg+" from job_result where job_id=? and deleted=? and end_time is not null",
+		jobId, false).Scan(&micros)
+	if err != nil {
+		return nil, err
+	}
+	stats.AvgDuration = time.Duration(micros.Float64) * time.Microsecond
+
+	return stats, nil
+}
+
+// 查询最近一次开始执行时间，state为空表示不限执行状态，没有记录时保持零值
+func (store *sqlStore) lastStartTime(jobId, state string, last *time.Time) error {
+	query := "select start_time from job_result where job_id=? and deleted=?"
+	args := []interface{}{jobId, false}
+	if state != "" {
+		query += " and execute_state=?"
+		args = append(args, state)
+	}
+	err := store.db.QueryRow(query+" order by start_time desc limit 1", args...).Scan(last)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (store *sqlStore) LastScheduledStartTime(jobId string) (time.Time, error) {
+	var last time.Time
+	err := store.db.QueryRow("select start_time from job_result where job_id=? and deleted=? and manual=? "+
+		"order by start_time desc limit 1", jobId, false, false).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return last, err
+}
+
+func (store *sqlStore) HeartbeatResult(id string, t time.Time) error {
+	_, err := store.db.Exec("update job_result set heartbeat_time=? where id=? and execute_state=?",
+		t, id, RunningJobExecuteState)
+	return err
+}
+
+func (store *sqlStore) AbandonResults(staleBefore, now time.Time, log string) (int64, error) {
+	res, err := store.db.Exec("update job_result set execute_state=?,end_time=coalesce(heartbeat_time,start_time),"+
+		"utime=?,log=? where execute_state=? and coalesce(heartbeat_time,start_time)<?",
+		AbandonedJobExecuteState, now, log, RunningJobExecuteState, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (store *sqlStore) InterruptResults(originIds []string, now time.Time, log string) (int64, error) {
+	if len(originIds) == 0 {
+		return 0, nil
+	}
+	args := []interface{}{InterruptedJobExecuteState, now, now, log, RunningJobExecuteState}
+	for _, id := range originIds {
+		args = append(args, id)
+	}
+	res, err := store.db.Exec("update job_result set execute_state=?,end_time=?,utime=?,log=? "+
+		"where execute_state=? and origin_id in (?"+strings.Repeat(",?", len(originIds)-1)+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
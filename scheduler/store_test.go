@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/xnffdd/gospider/database"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store Store) {
+	job := &Job{JobCore: JobCore{Id: "job-1", Name: "微信", CronRule: "0 0 0 * * *",
+		RunnerName: "WeiXinArticle", RunnerArgs: "golang"}}
+	created := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	if _, err := store.InsertJob(job, created); err != nil {
+		t.Fatal(err)
+	}
+	if !job.CreateTime.Equal(created) {
+		t.Errorf("插入作业后未回写创建时间：%v", job.CreateTime)
+	}
+
+	job.Opened = true
+	job.Name = "微信文章"
+	updated := created.Add(time.Hour)
+	if _, err := store.UpdateJob(job, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Name != "微信文章" || !jobs[0].Opened || jobs[0].RunnerArgs != "golang" ||
+		!jobs[0].CreateTime.Equal(created) || !jobs[0].UpdateTime.Equal(updated) {
+		t.Fatalf("加载作业不符合预期：%+v", jobs)
+	}
+
+	result := NewJobResult(job, store)
+	if err = result.SaveAtStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err = result.SaveAtEnd(false, "任务执行返回错误"); err != nil {
+		t.Fatal(err)
+	}
+	retry := result.nextAttempt()
+	if err = retry.SaveAtStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err = retry.SaveAtEnd(true, "任务执行成功"); err != nil {
+		t.Fatal(err)
+	}
+	results, total, err := store.QueryResults(&ResultQuery{OriginId: result.id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || results[0].Id != retry.id || results[0].Attempt != 2 || results[0].OriginId != result.id ||
+		results[1].Attempt != 1 || results[1].EndTime.IsZero() {
+		t.Errorf("重试执行记录不符合预期：%+v", results)
+	}
+	last, err := store.LastScheduledStartTime(job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.Equal(retry.startTime) {
+		t.Errorf("最近一次定时执行的开始时间不符合预期：%v", last)
+	}
+
+	if _, err = store.DeleteJob(job, updated.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = store.LoadJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("删除后仍加载到作业%d个", len(jobs))
+	}
+}
+
+func testResultQuery(t *testing.T, store Store) {
+	base := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	states := []string{SuccessJobExecuteState, FailJobExecuteState, SuccessJobExecuteState, FailJobExecuteState,
+		RunningJobExecuteState}
+	for i, state := range states {
+		start := base.Add(time.Duration(i) * time.Hour)
+		result := &JobResult{id: fmt.Sprintf("result-%d", i), jobId: "job-q", createTime: start, updateTime: start,
+			startTime: start, executeState: state}
+		if err := store.InsertResult(result); err != nil {
+			t.Fatal(err)
+		}
+		if state != RunningJobExecuteState {
+			result.endTime = start.Add(time.Duration(i+1) * time.Second)
+			if err := store.UpdateResult(result); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	results, total, err := store.QueryResults(&ResultQuery{JobId: "job-q", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(results) != 2 || results[0].Id != "result-3" || results[1].Id != "result-2" {
+		t.Errorf("分页查询不符合预期，total=%d，results=%+v", total, results)
+	}
+	if results[0].Duration() != 4*time.Second {
+		t.Errorf("期望耗时4s，实际%v", results[0].Duration())
+	}
+
+	results, total, err = store.QueryResults(&ResultQuery{JobId: "job-q", States: []string{FailJobExecuteState},
+		From: base.Add(2 * time.Hour), To: base.Add(4 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(results) != 1 || results[0].Id != "result-3" {
+		t.Errorf("按状态和时间范围查询不符合预期，total=%d，results=%+v", total, results)
+	}
+	if !results[0].StartTime.Equal(base.Add(3 * time.Hour)) {
+		t.Errorf("开始时间不符合预期：%v", results[0].StartTime)
+	}
+
+	stats, err := store.GetResultStats("job-q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 5 || stats.FailCount() != 2 || stats.Counts[SuccessJobExecuteState] != 2 ||
+		!stats.LastStartTime.Equal(base.Add(4*time.Hour)) ||
+		!stats.LastSuccessTime.Equal(base.Add(2*time.Hour)) ||
+		!stats.LastFailTime.Equal(base.Add(3*time.Hour)) {
+		t.Errorf("执行统计不符合预期：%+v", stats)
+	}
+	if d := stats.AvgDuration - 2500*time.Millisecond; d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("期望平均耗时2.5s，实际%v", stats.AvgDuration)
+	}
+}
+
+func testResultHeartbeat(t *testing.T, store Store) {
+	base := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	clock := NewFakeClock(base)
+	job := &Job{JobCore: JobCore{Id: "job-h", Name: "心跳"}}
+
+	alive := newJobResult(job, store, clock, "node-1")
+	orphan := newJobResult(job, store, clock, "node-0")
+	ended := newJobResult(job, store, clock, "node-1")
+	for _, result := range []*JobResult{alive, orphan, ended} {
+		if err := result.SaveAtStart(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ended.SaveAtEnd(true, "任务执行成功"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	for _, result := range []*JobResult{alive, ended} {
+		if err := result.SaveHeartbeat(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	affect, err := store.AbandonResults(base.Add(time.Minute), clock.Now(), "调度器实例失联")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affect != 1 {
+		t.Errorf("期望回收1个执行记录，实际%d个", affect)
+	}
+
+	results, _, err := store.QueryResults(&ResultQuery{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*Result)
+	for _, r := range results {
+		got[r.Id] = r
+	}
+	if r := got[alive.id]; r.ExecuteState != RunningJobExecuteState || r.Owner != "node-1" ||
+		!r.HeartbeatTime.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("心跳正常的执行记录不符合预期：%+v", r)
+	}
+	if r := got[orphan.id]; r.ExecuteState != AbandonedJobExecuteState || r.Owner != "node-0" ||
+		!r.EndTime.Equal(base) || r.Log != "调度器实例失联" {
+		t.Errorf("失联的执行记录不符合预期：%+v", r)
+	}
+	if r := got[ended.id]; r.ExecuteState != SuccessJobExecuteState || !r.HeartbeatTime.Equal(base) {
+		t.Errorf("已结束的执行记录不应更新心跳：%+v", r)
+	}
+
+	// 调度器关闭时只标记仍在执行中的指定执行
+	clock.Advance(time.Minute)
+	affect, err = store.InterruptResults([]string{alive.id, ended.id}, clock.Now(), "调度器关闭")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affect != 1 {
+		t.Errorf("期望标记1个执行记录，实际%d个", affect)
+	}
+	if affect, err = store.InterruptResults(nil, clock.Now(), "调度器关闭"); err != nil || affect != 0 {
+		t.Errorf("没有执行时不应标记执行记录：%d，%v", affect, err)
+	}
+	results, _, err = store.QueryResults(&ResultQuery{OriginId: alive.id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ExecuteState != InterruptedJobExecuteState ||
+		!results[0].EndTime.Equal(clock.Now()) || results[0].Log != "调度器关闭" {
+		t.Errorf("被中断的执行记录不符合预期：%+v", results)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+	testResultQuery(t, NewMemoryStore())
+	testResultHeartbeat(t, NewMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	if !database.SQLiteSupported {
+		t.Skip("未启用cgo，跳过SQLite持久化测试")
+	}
+	dir, err := ioutil.TempDir("", "gospider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := database.OpenSQLite(filepath.Join(dir, "gospider.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testStore(t, NewSQLiteStore(db))
+	testResultQuery(t, NewSQLiteStore(db))
+	testResultHeartbeat(t, NewSQLiteStore(db))
+}
@@ -1,22 +1,69 @@
 package spiders
 
 import (
+	"context"
 	"fmt"
 	"github.com/xnffdd/gospider/spiders/weixin"
+	"sync"
 )
 
-var runners map[string]func(string) error
+// 爬虫运行函数，ctx被取消（超时或被终止）时应尽快返回
+type Runner func(ctx context.Context, args string) error
+
+// 将不支持取消的旧式运行函数适配为Runner
+// ctx被取消时立即返回ctx.Err()，但旧式运行函数本身无法被中断，会在后台继续运行直至结束
+func Adapt(run func(string) error) Runner {
+	return func(ctx context.Context, args string) error {
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					done <- fmt.Errorf("爬虫宕机，%v", r)
+				}
+			}()
+			done <- run(args)
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+var (
+	runnersMu sync.RWMutex
+	runners   map[string]Runner
+)
+
+// 注册爬虫，通常在init中调用，名称重复或runner为nil时宕机
+func Register(name string, runner Runner) {
+	if runner == nil {
+		panic("注册爬虫失败，运行函数为nil，名称：" + name)
+	}
+	runnersMu.Lock()
+	defer runnersMu.Unlock()
+	if _, ok := runners[name]; ok {
+		panic("注册爬虫失败，名称重复：" + name)
+	}
+	runners[name] = runner
+}
 
 func GetRunnerNames() []string {
+	runnersMu.RLock()
+	defer runnersMu.RUnlock()
 	var names []string
-	for k, _ := range runners {
+	for k := range runners {
 		names = append(names, k)
 	}
 	return names
 }
 
-func GetRunnerByName(name string) (func(string) error, error) {
+func GetRunnerByName(name string) (Runner, error) {
+	runnersMu.RLock()
 	runner, ok := runners[name]
+	runnersMu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("爬虫不存在，名称：%v", name)
 	}
@@ -24,7 +71,7 @@ func GetRunnerByName(name string) (func(string) error, error) {
 }
 
 func init() {
-	runners = map[string]func(string) error{
+	runners = map[string]Runner{
 		"WeiXinArticle": weixin.Crawl,
 	}
 }
@@ -1,17 +1,23 @@
 package weixin
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 )
 
-func Crawl(keyword string) error {
+func Crawl(ctx context.Context, keyword string) error {
 	fmt.Printf("开始爬取微信，关键词：%s\n", keyword)
 	rand.Seed(time.Now().UnixNano())
 	x := rand.Intn(10)
 	fmt.Printf("休眠%d秒来模拟爬虫采集程序...\n", x)
-	time.Sleep(time.Duration(x) * time.Second)
+	select {
+	case <-time.After(time.Duration(x) * time.Second):
+	case <-ctx.Done():
+		fmt.Println("微信爬虫被中断")
+		return ctx.Err()
+	}
 	fmt.Println("微信爬虫成功结束")
 	return nil
 }